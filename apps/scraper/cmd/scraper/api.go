@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"indie-marketplace/scraper/internal/scheduler"
+	"indie-marketplace/scraper/internal/storage"
+	"indie-marketplace/scraper/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// adminAPI is the scraper's HTTP control surface: sync triggers, run progress, sync logs,
+// pausing, and the health checks
+type adminAPI struct {
+	db     *storage.DB
+	sched  *scheduler.Scheduler
+	logger *zap.SugaredLogger
+	token  string // Bearer token required by every endpoint but the health checks
+}
+
+// newAdminServer creates the admin HTTP server listening on addr. Without a token only
+// the health checks are served.
+func newAdminServer(addr, token string, db *storage.DB, sched *scheduler.Scheduler, logger *zap.SugaredLogger) *http.Server {
+	api := &adminAPI{db: db, sched: sched, logger: logger, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", api.healthz)
+	mux.HandleFunc("GET /readyz", api.readyz)
+	if token != "" {
+		mux.Handle("GET /status", api.auth(api.status))
+		mux.Handle("POST /sync", api.auth(api.syncAll))
+		mux.Handle("POST /brands/{id}/sync", api.auth(api.syncBrand))
+		mux.Handle("GET /sync-logs", api.auth(api.syncLogs))
+		mux.Handle("POST /pause", api.auth(api.pause))
+		mux.Handle("POST /resume", api.auth(api.resume))
+	} else {
+		logger.Warn("SCRAPER_ADMIN_TOKEN is not set, the admin API only serves the health checks")
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// auth rejects requests without the admin token
+func (a *adminAPI) auth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(a.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next(w, r)
+	})
+}
+
+// healthz reports the process is up
+func (a *adminAPI) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz reports whether the database is reachable
+func (a *adminAPI) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	if err := a.db.Ping(ctx); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// status shows the queue and the progress of the syncs running on this replica
+func (a *adminAPI) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.sched.Status())
+}
+
+// syncAll queues every active brand
+func (a *adminAPI) syncAll(w http.ResponseWriter, r *http.Request) {
+	queued, err := a.sched.QueueAll(r.Context())
+	if err != nil {
+		a.logger.Errorf("Failed to queue brands: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int{"queued": queued})
+}
+
+// syncBrand queues one brand
+func (a *adminAPI) syncBrand(w http.ResponseWriter, r *http.Request) {
+	queued, err := a.sched.QueueBrand(r.Context(), r.PathValue("id"))
+	if errors.Is(err, scheduler.ErrUnknownBrand) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		a.logger.Errorf("Failed to queue brand %s: %v", r.PathValue("id"), err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]bool{"queued": queued})
+}
+
+// syncLogs lists recent sync runs, filtered by brand_id, status and since (RFC 3339),
+// at most limit of them
+func (a *adminAPI) syncLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := storage.SyncLogFilter{
+		BrandID: q.Get("brand_id"),
+		Status:  q.Get("status"),
+	}
+
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("since must be an RFC 3339 time"))
+			return
+		}
+		filter.Since = since
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 500 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be between 1 and 500"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	logs, err := a.db.ListSyncLogs(r.Context(), filter)
+	if err != nil {
+		a.logger.Errorf("Failed to list sync logs: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if logs == nil {
+		logs = []models.SyncLog{}
+	}
+	writeJSON(w, http.StatusOK, logs)
+}
+
+// pause stops new syncs from starting on every replica
+func (a *adminAPI) pause(w http.ResponseWriter, r *http.Request) {
+	if err := a.sched.Pause(r.Context()); err != nil {
+		a.logger.Errorf("Failed to pause: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a.sched.Status())
+}
+
+// resume lets queued syncs start again on every replica
+func (a *adminAPI) resume(w http.ResponseWriter, r *http.Request) {
+	if err := a.sched.Resume(r.Context()); err != nil {
+		a.logger.Errorf("Failed to resume: %v", err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a.sched.Status())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/scheduler"
+	"indie-marketplace/scraper/internal/shopify"
+	"indie-marketplace/scraper/internal/storage"
+	"indie-marketplace/scraper/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// runBrandCommand handles `scraper brand add <website-url>`: it detects the Shopify store
+// behind the URL, creates the brand inactive, runs a trial sync and only activates the
+// brand once products came through. Adding a brand whose trial sync failed retries it.
+func runBrandCommand(ctx context.Context, db *storage.DB, logger *zap.SugaredLogger, config scheduler.Config, args []string) error {
+	if len(args) != 2 || args[0] != "add" {
+		return fmt.Errorf("usage: scraper brand add <website-url>")
+	}
+
+	client := shopify.NewClient(fetcher.New(config.Fetcher))
+	info, err := client.DetectStore(ctx, args[1])
+	if err != nil {
+		return fmt.Errorf("failed to detect store: %w", err)
+	}
+	logger.Infof("Detected Shopify store %s (%s, myshopify domain: %q)", info.Name, info.Domain, info.MyshopifyDomain)
+
+	brand := models.Brand{
+		Name:            info.Name,
+		Slug:            slugify(info.Name),
+		Description:     optional(info.Description),
+		LogoURL:         optional(info.LogoURL),
+		WebsiteURL:      info.WebsiteURL,
+		ShopifyDomain:   info.Domain,
+		MyshopifyDomain: optional(info.MyshopifyDomain),
+		Platform:        models.PlatformShopify,
+		Country:         optional(info.Country),
+		Currency:        optional(info.Currency),
+	}
+
+	brand.ID, err = db.CreateBrand(ctx, brand)
+	switch {
+	case errors.Is(err, storage.ErrBrandExists):
+		existing, lookupErr := db.GetBrandByDomain(ctx, brand.ShopifyDomain)
+		if lookupErr != nil {
+			return lookupErr
+		}
+		if existing == nil || existing.Status != models.BrandStatusPending {
+			return err
+		}
+		brand = *existing
+		logger.Infof("Brand %s (%s) is still pending, retrying its trial sync", brand.Name, brand.ID)
+	case err != nil:
+		return err
+	default:
+		logger.Infof("Created brand %s (%s), running a trial sync", brand.Name, brand.ID)
+	}
+
+	result := scheduler.New(db, logger, config).SyncBrand(ctx, brand)
+	if result.Error != nil {
+		return fmt.Errorf("trial sync failed, brand %s left pending until added again: %w", brand.ID, result.Error)
+	}
+	if result.ProductsFound == 0 {
+		return fmt.Errorf("trial sync found no products, brand %s left pending until added again", brand.ID)
+	}
+
+	if err := db.ActivateBrand(ctx, brand.ID); err != nil {
+		return err
+	}
+	logger.Infof("Activated %s with %d products", brand.Name, result.ProductsFound)
+
+	return nil
+}
+
+// slugify turns a brand name into a URL slug: "Atelier Näma & Co" becomes "atelier-näma-co"
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// optional returns nil for an empty string, for nullable columns
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
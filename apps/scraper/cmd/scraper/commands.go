@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"indie-marketplace/scraper/internal/rates"
+	"indie-marketplace/scraper/internal/scheduler"
+	"indie-marketplace/scraper/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// runCommand dispatches the one-off subcommands, e.g. `scraper rates backfill`
+func runCommand(ctx context.Context, db *storage.DB, logger *zap.SugaredLogger, config scheduler.Config, args []string) error {
+	switch args[0] {
+	case "brand":
+		return runBrandCommand(ctx, db, logger, config, args[1:])
+	case "rates":
+		return runRatesCommand(ctx, db, logger, config, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runRatesCommand handles `scraper rates backfill`: it imports the rate files from
+// SCRAPER_RATES_DIR, if set, then recomputes the EUR prices of every product
+func runRatesCommand(ctx context.Context, db *storage.DB, logger *zap.SugaredLogger, config scheduler.Config, args []string) error {
+	if len(args) == 0 || args[0] != "backfill" {
+		return fmt.Errorf("usage: scraper rates backfill")
+	}
+
+	if config.RatesDir != "" {
+		loaded, err := rates.LoadDir(config.RatesDir)
+		if err != nil {
+			return err
+		}
+		written, err := db.UpsertExchangeRates(ctx, loaded)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Imported %d exchange rates from %s", written, config.RatesDir)
+	}
+
+	repriced, err := db.BackfillEURPrices(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Recomputed EUR prices for %d products", repriced)
+
+	return nil
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"indie-marketplace/scraper/internal/scheduler"
 	"indie-marketplace/scraper/internal/storage"
@@ -34,8 +38,11 @@ func main() {
 	// Check if running in one-shot mode (for cron jobs)
 	runOnce := strings.ToLower(os.Getenv("RUN_ONCE")) == "true"
 
+	// The root context is cancelled on SIGINT or SIGTERM, interrupting the syncs in progress
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize database connection
-	ctx := context.Background()
 	db, err := storage.NewDB(ctx, dbURL)
 	if err != nil {
 		sugar.Fatalf("Failed to connect to database: %v", err)
@@ -44,29 +51,104 @@ func main() {
 
 	sugar.Info("Connected to database")
 
+	config := loadConfig()
+
+	// Subcommands run once against the database and exit
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, db, sugar, config, os.Args[1:]); err != nil {
+			sugar.Fatalf("Command failed: %v", err)
+		}
+		return
+	}
+
 	// Initialize scheduler
-	sched := scheduler.New(db, sugar)
+	sched := scheduler.New(db, sugar, config)
 
 	if runOnce {
 		// One-shot mode: run sync once and exit
 		sugar.Info("Running in one-shot mode (RUN_ONCE=true)")
-		sched.RunSyncNow()
+		sched.RunSyncNow(ctx)
+		if ctx.Err() != nil {
+			sugar.Info("One-shot sync interrupted")
+			return
+		}
 		sugar.Info("One-shot sync completed")
 		return
 	}
 
 	// Start the scheduler (continuous mode)
-	sched.Start()
+	sched.Start(ctx)
 	sugar.Info("Scheduler started")
 
-	// Wait for interrupt signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	var admin *http.Server
+	if addr := os.Getenv("SCRAPER_ADMIN_ADDR"); addr != "" {
+		admin = newAdminServer(addr, os.Getenv("SCRAPER_ADMIN_TOKEN"), db, sched, sugar)
+		go func() {
+			if err := admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				sugar.Errorf("Admin server failed: %v", err)
+			}
+		}()
+		sugar.Infof("Admin API listening on %s", addr)
+	}
 
-	<-sigChan
+	// Wait for interrupt signal; a second one kills the process right away
+	<-ctx.Done()
+	stop()
 	sugar.Info("Shutting down...")
 
-	// Stop the scheduler
+	if admin != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := admin.Shutdown(shutdownCtx); err != nil {
+			sugar.Errorf("Failed to stop admin server: %v", err)
+		}
+		cancel()
+	}
+
+	// Stop the scheduler, waiting for the interrupted syncs to record themselves
 	sched.Stop()
 	sugar.Info("Scheduler stopped")
 }
+
+// loadConfig builds the scheduler configuration from environment variables
+func loadConfig() scheduler.Config {
+	config := scheduler.DefaultConfig()
+
+	if ua := os.Getenv("SCRAPER_USER_AGENT"); ua != "" {
+		config.Fetcher.UserAgent = ua
+	}
+	if ms := envInt("SCRAPER_REQUEST_DELAY_MS", 0); ms > 0 {
+		config.Fetcher.RequestDelay = time.Duration(ms) * time.Millisecond
+	}
+	config.Fetcher.HostConcurrency = envInt("SCRAPER_HOST_CONCURRENCY", config.Fetcher.HostConcurrency)
+	config.MaxWorkers = envInt("SCRAPER_MAX_CONCURRENCY", config.MaxWorkers)
+	if interval := os.Getenv("SCRAPER_SYNC_INTERVAL"); interval != "" {
+		config.SyncInterval = interval
+	}
+	if mins := envInt("SCRAPER_MIN_SYNC_INTERVAL_MINUTES", 0); mins > 0 {
+		config.MinSyncInterval = time.Duration(mins) * time.Minute
+	}
+	if mins := envInt("SCRAPER_MAX_SYNC_INTERVAL_MINUTES", -1); mins >= 0 {
+		config.MaxSyncInterval = time.Duration(mins) * time.Minute
+	}
+	config.RatesDir = os.Getenv("SCRAPER_RATES_DIR")
+	config.EnrichBudget = envInt("SCRAPER_ENRICH_BUDGET", config.EnrichBudget)
+	config.CrawlBudget = envInt("SCRAPER_CRAWL_BUDGET", config.CrawlBudget)
+	config.MaxFailures = envInt("SCRAPER_MAX_FAILURES", config.MaxFailures)
+	if id := os.Getenv("SCRAPER_REPLICA_ID"); id != "" {
+		config.ReplicaID = id
+	}
+	if secs := envInt("SCRAPER_LEASE_TTL_SECONDS", 0); secs > 0 {
+		config.LeaseTTL = time.Duration(secs) * time.Second
+	}
+
+	return config
+}
+
+// envInt reads an integer environment variable, returning fallback when unset or invalid
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
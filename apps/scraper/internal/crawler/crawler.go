@@ -0,0 +1,305 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/parser"
+	"indie-marketplace/scraper/internal/robots"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// maxChildSitemaps caps how many sitemaps of a sitemap index are read
+const maxChildSitemaps = 50
+
+// batchSize is how many products are collected before they are handed over
+const batchSize = 50
+
+// skippedExtensions are links to assets rather than pages
+var skippedExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".svg": true,
+	".pdf": true, ".zip": true, ".css": true, ".js": true, ".xml": true, ".mp4": true,
+}
+
+// trackingParams are query parameters that only identify where a visitor came from,
+// besides the utm_ ones
+var trackingParams = map[string]bool{
+	"gclid": true, "fbclid": true, "msclkid": true, "mc_cid": true, "mc_eid": true,
+	"_ga": true, "_pos": true, "_sid": true, "_ss": true, "ref": true,
+}
+
+// Site describes where to crawl a brand's website
+type Site struct {
+	Domain     string // Host the crawl stays on, a leading "www." is ignored
+	StartURL   string // First page to visit, absolute or relative to the home page, which is the default
+	SitemapURL string // Sitemap seeding the crawl, else those listed in robots.txt, else /sitemap.xml
+	MaxPages   int    // Page budget, sitemaps and robots.txt excluded
+}
+
+// Result sums up a crawl
+type Result struct {
+	Products  int  // Products found
+	Pages     int  // Pages fetched
+	Exhausted bool // Every reachable page was visited within the budget without errors
+}
+
+// Crawler walks a website breadth-first looking for schema.org Product pages
+type Crawler struct {
+	fetcher *fetcher.Fetcher
+	parser  *parser.HTMLParser
+}
+
+// New creates a new crawler on top of a shared fetcher
+func New(f *fetcher.Fetcher) *Crawler {
+	return &Crawler{
+		fetcher: f,
+		parser:  parser.NewHTMLParser(),
+	}
+}
+
+// Crawl visits the sitemap's pages and then the pages linked from the start URL, staying on
+// the site's domain and honouring its robots.txt, until the page budget is spent. Products
+// are keyed by canonical URL, so one reachable under several URLs is only handed over once.
+// They go to fn in batches, with the first priceCurrency seen so far (empty when none was).
+// An error from fn stops the crawl and is returned as is.
+func (c *Crawler) Crawl(ctx context.Context, site Site, fn func(products []models.ShopifyProduct, currency string) error) (*Result, error) {
+	start, err := startURL(site)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := c.fetcher.Robots(ctx, fmt.Sprintf("https://%s/", site.Domain))
+	if err != nil {
+		return nil, err
+	}
+
+	var queue []string
+	seen := make(map[string]bool)
+	enqueue := func(raw string) {
+		u, err := url.Parse(raw)
+		if err != nil || !sameSite(u.Hostname(), site.Domain) || skippedExtensions[strings.ToLower(path.Ext(u.Path))] {
+			return
+		}
+		key := normalizeURL(u)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		queue = append(queue, key)
+	}
+
+	for _, u := range c.sitemapURLs(ctx, site, rules) {
+		enqueue(u)
+	}
+	enqueue(start)
+
+	result := &Result{}
+	products := make(map[string]bool)
+	failed := false
+	disallowed := 0
+
+	var batch []models.ShopifyProduct
+	var currency string
+
+	for len(queue) > 0 && result.Pages < site.MaxPages {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		pageURL := queue[0]
+		queue = queue[1:]
+
+		// Skipped here rather than by the fetcher so disallowed pages don't eat the budget
+		u, _ := url.Parse(pageURL)
+		if !rules.Allowed(u.RequestURI()) {
+			disallowed++
+			continue
+		}
+
+		result.Pages++
+		html, err := c.fetcher.GetBody(ctx, pageURL, "text/html")
+		if err != nil {
+			failed = true
+			continue
+		}
+
+		page, err := c.parser.ParseSchemaPage(html, u)
+		if err != nil {
+			failed = true
+			continue
+		}
+
+		for _, link := range page.Links {
+			enqueue(link)
+		}
+
+		if page.Product == nil || page.Product.Title == "" || products[page.CanonicalURL] {
+			continue
+		}
+		products[page.CanonicalURL] = true
+
+		if currency == "" {
+			currency = page.Currency
+		}
+		result.Products++
+		batch = append(batch, keyByURL(*page.Product, page.CanonicalURL))
+
+		if len(batch) >= batchSize {
+			if err := fn(batch, currency); err != nil {
+				return nil, err
+			}
+			batch = nil
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := fn(batch, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	// A site whose every page is off limits is blocking us, not empty
+	if result.Pages == 0 && disallowed > 0 {
+		return nil, &fetcher.DisallowedError{URL: start}
+	}
+
+	result.Exhausted = len(queue) == 0 && !failed
+	return result, nil
+}
+
+// startURL resolves the site's start URL against its home page. A start URL off the site
+// is an error rather than skipped, which would leave the crawl to the sitemap alone.
+func startURL(site Site) (string, error) {
+	home := &url.URL{Scheme: "https", Host: site.Domain, Path: "/"}
+	if site.StartURL == "" {
+		return home.String(), nil
+	}
+
+	u, err := home.Parse(site.StartURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid start URL %q: %w", site.StartURL, err)
+	}
+	if !sameSite(u.Hostname(), site.Domain) {
+		return "", fmt.Errorf("start URL %s is not on %s", site.StartURL, site.Domain)
+	}
+	return u.String(), nil
+}
+
+// sitemapURLs lists the page URLs of the site's sitemap, following one level of index.
+// Sitemaps are only a seed, so failures just leave the crawl to the links.
+func (c *Crawler) sitemapURLs(ctx context.Context, site Site, rules *robots.Rules) []string {
+	roots := rules.Sitemaps()
+	if site.SitemapURL != "" {
+		roots = []string{site.SitemapURL}
+	}
+	if len(roots) == 0 {
+		roots = []string{fmt.Sprintf("https://%s/sitemap.xml", site.Domain)}
+	}
+
+	var urls []string
+	children := 0
+	for _, root := range roots {
+		sitemap, err := c.fetchSitemap(ctx, root)
+		if err != nil {
+			continue
+		}
+		for _, e := range sitemap.URLs {
+			urls = append(urls, e.Loc)
+		}
+
+		for _, child := range sitemap.Sitemaps {
+			if children >= maxChildSitemaps {
+				break
+			}
+			children++
+
+			sub, err := c.fetchSitemap(ctx, child.Loc)
+			if err != nil {
+				continue
+			}
+			for _, e := range sub.URLs {
+				urls = append(urls, e.Loc)
+			}
+		}
+	}
+
+	return urls
+}
+
+// fetchSitemap downloads and parses a single sitemap document
+func (c *Crawler) fetchSitemap(ctx context.Context, sitemapURL string) (*parser.Sitemap, error) {
+	body, err := c.fetcher.GetBody(ctx, sitemapURL, "application/xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap: %w", err)
+	}
+
+	return parser.ParseSitemap([]byte(body))
+}
+
+// keyByURL gives a crawled product, and its variants, stable IDs derived from its canonical URL
+func keyByURL(sp models.ShopifyProduct, canonical string) models.ShopifyProduct {
+	sp.ID = models.SyntheticID("url:" + canonical)
+	sp.CanonicalURL = canonical
+
+	if sp.Handle == "" {
+		if u, err := url.Parse(canonical); err == nil {
+			sp.Handle = path.Base(strings.TrimSuffix(u.Path, "/"))
+		}
+		if sp.Handle == "." || sp.Handle == "/" || sp.Handle == "" {
+			sp.Handle = fmt.Sprintf("product-%d", sp.ID)
+		}
+	}
+
+	for i := range sp.Variants {
+		v := &sp.Variants[i]
+		v.ProductID = sp.ID
+		if v.ID == 0 {
+			key := v.SKU
+			if key == "" {
+				key = fmt.Sprintf("#%d", i)
+			}
+			v.ID = models.SyntheticID("url:" + canonical + "|" + key)
+		}
+		if v.Title == "" {
+			v.Title = "Default Title"
+		}
+	}
+
+	return sp
+}
+
+// normalizeURL drops what doesn't change the page a URL points to: the fragment, tracking
+// parameters, a trailing slash and the case of the host. Query parameters are sorted.
+func normalizeURL(u *url.URL) string {
+	n := *u
+	n.Fragment = ""
+	n.RawFragment = ""
+	n.Host = strings.ToLower(n.Host)
+
+	q := n.Query()
+	for key := range q {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			q.Del(key)
+		}
+	}
+	n.RawQuery = q.Encode()
+	n.ForceQuery = false
+
+	if len(n.Path) > 1 {
+		n.Path = strings.TrimSuffix(n.Path, "/")
+		n.RawPath = ""
+	}
+
+	return n.String()
+}
+
+// sameSite reports whether host belongs to domain, treating www. as the same site
+func sameSite(host, domain string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	domain = strings.TrimPrefix(strings.ToLower(domain), "www.")
+	return host == domain
+}
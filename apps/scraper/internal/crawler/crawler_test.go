@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/httpfixture"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+func TestStartURL(t *testing.T) {
+	tests := []struct {
+		start   string
+		want    string
+		wantErr bool
+	}{
+		{"", "https://shop.example/", false},
+		{"/collections/all", "https://shop.example/collections/all", false},
+		{"https://www.shop.example/shop", "https://www.shop.example/shop", false},
+		{"https://elsewhere.example/shop", "", true},
+		{"http://%zz", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := startURL(Site{Domain: "shop.example", StartURL: tt.start})
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("startURL(%q) = %q, %v; want %q, error %v", tt.start, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"https://Shop.Example/products/mug", "https://shop.example/products/mug"},
+		{"https://shop.example/products/mug/", "https://shop.example/products/mug"},
+		{"https://shop.example/products/mug#reviews", "https://shop.example/products/mug"},
+		{"https://shop.example/products/mug?utm_source=x&gclid=1", "https://shop.example/products/mug"},
+		{"https://shop.example/search?q=mug&page=2", "https://shop.example/search?page=2&q=mug"},
+		{"https://shop.example/", "https://shop.example/"},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.raw)
+		if err != nil {
+			t.Fatalf("url.Parse(%q): %v", tt.raw, err)
+		}
+		if got := normalizeURL(u); got != tt.want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+// site serves a small brand website: robots.txt, a sitemap, a home page and product pages
+// reachable under several URLs
+func site() http.Handler {
+	page := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<html><body>%s</body></html>", body)
+		}
+	}
+	product := func(name, price string) http.HandlerFunc {
+		return page(fmt.Sprintf(`<script type="application/ld+json">{"@context":"https://schema.org","@type":"Product",`+
+			`"name":%q,"offers":{"@type":"Offer","price":%q,"priceCurrency":"EUR",`+
+			`"availability":"https://schema.org/InStock"}}</script><a href="/">Home</a>`, name, price))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "User-agent: *\nDisallow: /cart\nSitemap: https://brand.example/sitemap.xml\n")
+	})
+	mux.HandleFunc("GET /sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+
+			`<url><loc>https://brand.example/products/mug</loc></url>`+
+			`<url><loc>https://brand.example/products/cup?utm_source=sitemap</loc></url></urlset>`)
+	})
+	mux.HandleFunc("GET /{$}", page(`<a href="/products/mug#reviews">Mug</a><a href="/products/cup/">Cup</a>`+
+		`<a href="/cart">Cart</a><a href="https://other.example/products/x">Elsewhere</a>`+
+		`<a href="/about">About</a><a href="/logo.png">Logo</a>`))
+	mux.HandleFunc("GET /about", page(`<a href="/products/mug?utm_campaign=about">Our mug</a>`))
+	mux.HandleFunc("GET /products/mug", product("Mug", "12.00"))
+	mux.HandleFunc("GET /products/cup", product("Cup", "8.50"))
+	return mux
+}
+
+func newTestCrawler() *Crawler {
+	config := fetcher.DefaultConfig()
+	config.RequestDelay = time.Millisecond
+	config.Transport = httpfixture.HandlerTransport{Handler: site()}
+	return New(fetcher.New(config))
+}
+
+func TestCrawl(t *testing.T) {
+	var products []models.ShopifyProduct
+	var currency string
+	result, err := newTestCrawler().Crawl(context.Background(), Site{Domain: "brand.example", MaxPages: 20},
+		func(batch []models.ShopifyProduct, c string) error {
+			products = append(products, batch...)
+			currency = c
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+
+	// Duplicate URLs, the disallowed cart, the other site and the image are never fetched
+	if result.Pages != 4 || result.Products != 2 || !result.Exhausted {
+		t.Fatalf("got %d pages, %d products, exhausted %v; want 4, 2, true", result.Pages, result.Products, result.Exhausted)
+	}
+	if len(products) != 2 || currency != "EUR" {
+		t.Fatalf("got %d products in %q, want 2 in EUR", len(products), currency)
+	}
+	for _, p := range products {
+		if p.ID == 0 || p.CanonicalURL == "" || len(p.Variants) != 1 || p.Variants[0].ID == 0 {
+			t.Errorf("product %q was not keyed by URL: %+v", p.Title, p)
+		}
+	}
+}
+
+func TestCrawlBudget(t *testing.T) {
+	result, err := newTestCrawler().Crawl(context.Background(), Site{Domain: "brand.example", MaxPages: 2},
+		func([]models.ShopifyProduct, string) error { return nil })
+	if err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+	if result.Pages != 2 || result.Exhausted {
+		t.Fatalf("got %d pages, exhausted %v; want 2, false", result.Pages, result.Exhausted)
+	}
+}
+
+func TestCrawlOffSiteStart(t *testing.T) {
+	_, err := newTestCrawler().Crawl(context.Background(), Site{Domain: "brand.example", StartURL: "https://other.example/", MaxPages: 20},
+		func([]models.ShopifyProduct, string) error { return nil })
+	if err == nil {
+		t.Fatal("Crawl accepted a start URL on another site")
+	}
+}
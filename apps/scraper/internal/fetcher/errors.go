@@ -0,0 +1,65 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// StatusError is returned when a host answers with an unexpected HTTP status
+type StatusError struct {
+	StatusCode int
+	URL        string
+	BotWall    bool // The response came from an anti-bot challenge rather than the site
+}
+
+// NewStatusError describes an unexpected response
+func NewStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		URL:        resp.Request.URL.String(),
+		BotWall:    isBotWall(resp),
+	}
+}
+
+func (e *StatusError) Error() string {
+	if e.BotWall {
+		return fmt.Sprintf("unexpected status code: %d (bot challenge)", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// isBotWall recognizes the challenge pages of the common bot protection services
+func isBotWall(resp *http.Response) bool {
+	h := resp.Header
+	switch {
+	case h.Get("Cf-Mitigated") == "challenge":
+		return true
+	case h.Get("X-Datadome") != "" || h.Get("X-Dd-B") != "":
+		return true
+	case h.Get("X-Sucuri-Block") != "":
+		return true
+	case h.Get("X-Amzn-Waf-Action") == "challenge" || h.Get("X-Amzn-Waf-Action") == "captcha":
+		return true
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusServiceUnavailable:
+		// Cloudflare and Kasada answer their challenges with 403 or 503
+		return strings.EqualFold(h.Get("Server"), "cloudflare") || h.Get("X-Kpsdk-Ct") != ""
+	}
+	return false
+}
+
+// DisallowedError is returned for URLs the host's robots.txt forbids us to fetch
+type DisallowedError struct {
+	URL string
+}
+
+func (e *DisallowedError) Error() string {
+	return fmt.Sprintf("disallowed by robots.txt: %s", e.URL)
+}
+
+// IsDisallowed reports whether err means robots.txt kept us from fetching a URL
+func IsDisallowed(err error) bool {
+	var de *DisallowedError
+	return errors.As(err, &de)
+}
@@ -0,0 +1,283 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"indie-marketplace/scraper/internal/robots"
+
+	"golang.org/x/time/rate"
+)
+
+// Config holds the configuration for the fetcher
+type Config struct {
+	UserAgent       string
+	RequestDelay    time.Duration // Minimum delay between requests to the same host
+	HostConcurrency int           // Max in-flight requests to the same host
+	MaxRetries      int
+	RequestTimeout  time.Duration
+	MaxRetryAfter   time.Duration     // Longest Retry-After we are willing to sleep through
+	RobotsTTL       time.Duration     // How long a host's robots.txt is cached
+	Transport       http.RoundTripper // Sends the requests, http.DefaultTransport when nil; swapped for fixtures offline
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		UserAgent:       "IndieMarketBot/1.0 (+https://indiemarket.com/bot)",
+		RequestDelay:    1 * time.Second,
+		HostConcurrency: 1,
+		MaxRetries:      3,
+		RequestTimeout:  30 * time.Second,
+		MaxRetryAfter:   2 * time.Minute,
+		RobotsTTL:       24 * time.Hour,
+	}
+}
+
+// Fetcher performs polite GET requests: each host gets its own rate limiter and
+// concurrency cap, its robots.txt is obeyed, and failed requests are retried with
+// jittered backoff
+type Fetcher struct {
+	httpClient *http.Client
+	config     Config
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+// hostLimiter paces and caps the requests sent to a single host
+type hostLimiter struct {
+	limiter *rate.Limiter
+	slots   chan struct{}
+
+	robotsMu sync.Mutex // Held while robots.txt is downloaded, so it is only fetched once
+	robots   *robots.Rules
+	robotsAt time.Time
+}
+
+// New creates a new fetcher
+func New(config Config) *Fetcher {
+	if config.HostConcurrency < 1 {
+		config.HostConcurrency = 1
+	}
+
+	f := &Fetcher{
+		config: config,
+		hosts:  make(map[string]*hostLimiter),
+	}
+	f.httpClient = &http.Client{
+		Timeout:       config.RequestTimeout,
+		Transport:     config.Transport,
+		CheckRedirect: f.checkRedirect,
+	}
+	return f
+}
+
+// maxRedirects is how many redirects a request follows, as http.Client does by default
+const maxRedirects = 10
+
+// checkRedirect holds redirects to the same rules as the requests they follow: the target
+// must be allowed by its host's robots.txt and waits for that host's rate limiter
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	// robots.txt itself is never checked, and checking a redirect of it would wait on
+	// the lock its download holds
+	for _, r := range append(via, req) {
+		if r.URL.Path == robotsPath {
+			return nil
+		}
+	}
+
+	rules, err := f.robotsFor(req.Context(), req.URL)
+	if err != nil {
+		return err
+	}
+	if !rules.Allowed(req.URL.RequestURI()) {
+		return &DisallowedError{URL: req.URL.String()}
+	}
+
+	if err := f.hostFor(req.URL.Host).limiter.Wait(req.Context()); err != nil {
+		return fmt.Errorf("rate limiter error: %w", err)
+	}
+	return nil
+}
+
+// hostFor returns the limiter for a host, creating it on first use
+func (f *Fetcher) hostFor(host string) *hostLimiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h, ok := f.hosts[host]
+	if !ok {
+		h = &hostLimiter{
+			limiter: rate.NewLimiter(rate.Every(f.config.RequestDelay), 1),
+			slots:   make(chan struct{}, f.config.HostConcurrency),
+		}
+		f.hosts[host] = h
+	}
+	return h
+}
+
+// Get performs a GET request while respecting the host's robots.txt and the per-host
+// rate and concurrency limits. The host slot is held until the body is closed.
+// URLs robots.txt forbids fail with a *DisallowedError without being requested.
+func (f *Fetcher) Get(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if req.URL.Path != robotsPath {
+		rules, err := f.robotsFor(ctx, req.URL)
+		if err != nil {
+			return nil, err
+		}
+		if !rules.Allowed(req.URL.RequestURI()) {
+			return nil, &DisallowedError{URL: rawURL}
+		}
+	}
+
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("Accept", accept)
+
+	host := f.hostFor(req.URL.Host)
+
+	select {
+	case host.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() { <-host.slots }
+
+	var resp *http.Response
+	var lastErr error
+	var retryAfter time.Duration
+
+	// Retry logic with exponential backoff
+	for attempt := 0; attempt <= f.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := retryAfter
+			if wait == 0 {
+				wait = backoff(attempt)
+			}
+			select {
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		if err := host.limiter.Wait(ctx); err != nil {
+			release()
+			return nil, fmt.Errorf("rate limiter error: %w", err)
+		}
+
+		retryAfter = 0
+		resp, lastErr = f.httpClient.Do(req)
+		if IsDisallowed(lastErr) {
+			// A redirect led somewhere robots.txt forbids, retrying won't change that
+			release()
+			return nil, lastErr
+		}
+		if lastErr != nil {
+			continue
+		}
+
+		// Check for rate limiting or server errors
+		if resp.StatusCode == 429 || resp.StatusCode >= 500 {
+			if resp.StatusCode == 429 || resp.StatusCode == 503 {
+				retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			}
+			resp.Body.Close()
+			lastErr = NewStatusError(resp)
+
+			if retryAfter > f.config.MaxRetryAfter {
+				lastErr = fmt.Errorf("Retry-After %s too long: %w", retryAfter, lastErr)
+				break
+			}
+			continue
+		}
+
+		break
+	}
+
+	if lastErr != nil {
+		release()
+		return nil, fmt.Errorf("all retries failed: %w", lastErr)
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// backoff returns the delay before a retry: attempt² seconds plus up to 50% jitter
+// so workers that failed together don't retry in lockstep
+func backoff(attempt int) time.Duration {
+	base := time.Duration(attempt*attempt) * time.Second
+	return base + time.Duration(rand.Int63n(int64(base)/2+1))
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(header); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+
+	return 0
+}
+
+// releasingBody frees the host slot once the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// GetBody downloads a page or document, failing with a *StatusError on anything but 200
+func (f *Fetcher) GetBody(ctx context.Context, pageURL, accept string) (string, error) {
+	resp, err := f.Get(ctx, pageURL, accept)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", NewStatusError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read page: %w", err)
+	}
+
+	return string(body), nil
+}
@@ -0,0 +1,73 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"indie-marketplace/scraper/internal/robots"
+
+	"golang.org/x/time/rate"
+)
+
+// robotsPath is the one path never checked against robots.txt
+const robotsPath = "/robots.txt"
+
+// Robots returns the robots.txt rules that apply to us on the host of rawURL
+func (f *Fetcher) Robots(ctx context.Context, rawURL string) (*robots.Rules, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	return f.robotsFor(ctx, u)
+}
+
+// robotsFor returns the cached rules of a host, downloading robots.txt when they are missing
+// or older than RobotsTTL. A Crawl-delay slower than RequestDelay becomes the host's pace.
+func (f *Fetcher) robotsFor(ctx context.Context, u *url.URL) (*robots.Rules, error) {
+	h := f.hostFor(u.Host)
+
+	h.robotsMu.Lock()
+	defer h.robotsMu.Unlock()
+
+	if h.robots != nil && time.Since(h.robotsAt) < f.config.RobotsTTL {
+		return h.robots, nil
+	}
+
+	rules, err := f.fetchRobots(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	delay := f.config.RequestDelay
+	if d := rules.CrawlDelay(); d > delay {
+		delay = d
+	}
+	h.limiter.SetLimit(rate.Every(delay))
+
+	h.robots = rules
+	h.robotsAt = time.Now()
+	return rules, nil
+}
+
+// fetchRobots downloads and parses a host's robots.txt. A 4xx means there are no
+// rules; a server error or unreachable host is returned so nothing gets cached.
+func (f *Fetcher) fetchRobots(ctx context.Context, u *url.URL) (*robots.Rules, error) {
+	robotsURL := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, robotsPath)
+
+	body, err := f.GetBody(ctx, robotsURL, "text/plain")
+	if err == nil {
+		return robots.Parse(body, f.config.UserAgent), nil
+	}
+
+	// 429 is a server asking us to back off, not a missing file
+	var se *StatusError
+	if errors.As(err, &se) && se.StatusCode >= 400 && se.StatusCode < 500 && se.StatusCode != http.StatusTooManyRequests && !se.BotWall {
+		return robots.AllowAll(), nil
+	}
+
+	return nil, fmt.Errorf("failed to fetch robots.txt: %w", err)
+}
@@ -0,0 +1,416 @@
+package httpfixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// maxPageSize is the largest products.json page Shopify serves
+const maxPageSize = 250
+
+// Store is a fake Shopify storefront serving a fixed catalog: paginated products.json,
+// product pages and their .js endpoint, /collections/all, the sitemap, /meta.json and
+// /cart.js. It can misbehave on demand, to exercise the scraper's fallbacks and retries.
+type Store struct {
+	Name       string
+	Currency   string
+	Products   []models.ShopifyProduct
+	PageSize   int              // Largest products.json page served whatever limit asks for, 250 when 0
+	LinkPaging bool             // Paginate with Link header cursors rather than since_id
+	Robots     string           // robots.txt body, 404 when empty
+	Password   bool             // Every page redirects to /password
+	BlockJSON  bool             // products.json answers 403, as on stores behind a bot wall
+	Faults     map[string][]int // Statuses served, in order, to the first requests of a path; 429s ask to retry after 1s
+
+	mu       sync.Mutex
+	mux      *http.ServeMux
+	requests map[string]int
+}
+
+// NewStore creates a fake store selling products in USD
+func NewStore(name string, products []models.ShopifyProduct) *Store {
+	s := &Store{
+		Name:     name,
+		Currency: "USD",
+		Products: products,
+		requests: make(map[string]int),
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /{$}", s.serveHome)
+	s.mux.HandleFunc("GET /robots.txt", s.serveRobots)
+	s.mux.HandleFunc("GET /products.json", s.serveProductsJSON)
+	s.mux.HandleFunc("GET /products/{handle}", s.serveProduct)
+	s.mux.HandleFunc("GET /collections/all", s.serveCollection)
+	s.mux.HandleFunc("GET /sitemap.xml", s.serveSitemapIndex)
+	s.mux.HandleFunc("GET /sitemap_products_1.xml", s.serveProductSitemap)
+	s.mux.HandleFunc("GET /meta.json", s.serveMeta)
+	s.mux.HandleFunc("GET /cart.js", s.serveMeta)
+	s.mux.HandleFunc("GET /password", s.servePassword)
+
+	return s
+}
+
+// Catalog generates n products with one variant and one image each, IDs 1 to n, the later ones
+// updated more recently, one minute apart starting at since
+func Catalog(n int, since time.Time) []models.ShopifyProduct {
+	products := make([]models.ShopifyProduct, n)
+	for i := range products {
+		id := int64(i + 1)
+		updated := since.Add(time.Duration(i) * time.Minute)
+		products[i] = models.ShopifyProduct{
+			ID:          id,
+			Title:       fmt.Sprintf("Product %d", id),
+			Handle:      fmt.Sprintf("product-%d", id),
+			BodyHTML:    fmt.Sprintf("<p>Product %d</p>", id),
+			Vendor:      "Fixture",
+			ProductType: "Mug",
+			Tags:        "ceramic, handmade",
+			PublishedAt: since,
+			CreatedAt:   since,
+			UpdatedAt:   updated,
+			Variants: []models.ShopifyVariant{{
+				ID:        id * 1000,
+				ProductID: id,
+				Title:     "Default Title",
+				SKU:       fmt.Sprintf("SKU-%d", id),
+				Price:     fmt.Sprintf("%d.00", 10+i%90),
+				Option1:   "Default Title",
+				Available: true,
+			}},
+			Images: []models.ShopifyImage{{
+				ID:        id * 10,
+				ProductID: id,
+				Src:       fmt.Sprintf("https://cdn.shopify.com/s/files/product-%d.jpg", id),
+				Position:  1,
+			}},
+		}
+	}
+	return products
+}
+
+// Requests returns how many requests were made for a path
+func (s *Store) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// ServeHTTP implements http.Handler
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	n := s.requests[r.URL.Path]
+	s.requests[r.URL.Path]++
+	s.mu.Unlock()
+
+	if faults := s.Faults[r.URL.Path]; n < len(faults) {
+		if faults[n] == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		http.Error(w, http.StatusText(faults[n]), faults[n])
+		return
+	}
+
+	if s.Password && r.URL.Path != "/password" {
+		http.Redirect(w, r, "/password", http.StatusFound)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// Transport returns a RoundTripper answering every request from the store in process,
+// whatever host it is addressed to, so it can stand in for the network in fetcher.Config
+func (s *Store) Transport() http.RoundTripper {
+	return HandlerTransport{Handler: s}
+}
+
+// HandlerTransport is a RoundTripper that serves requests with an http.Handler
+type HandlerTransport struct {
+	Handler http.Handler
+}
+
+// RoundTrip implements http.RoundTripper
+func (t HandlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	rec := httptest.NewRecorder()
+	t.Handler.ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func (s *Store) serveHome(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Shopify-Stage", "production")
+	fmt.Fprintf(w, `<html><head><title>%s</title><meta property="og:site_name" content="%s">`+
+		`<link rel="stylesheet" href="//cdn.shopify.com/s/files/theme.css"></head>`+
+		`<body><script>Shopify.currency = {"active":"%s","rate":"1.0"};</script></body></html>`,
+		template.HTMLEscapeString(s.Name), template.HTMLEscapeString(s.Name), s.Currency)
+}
+
+func (s *Store) serveRobots(w http.ResponseWriter, r *http.Request) {
+	if s.Robots == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, s.Robots)
+}
+
+// serveProductsJSON pages through the catalog in ID order, honouring limit, since_id,
+// updated_at_min and, with LinkPaging, the page_info cursors it hands out
+func (s *Store) serveProductsJSON(w http.ResponseWriter, r *http.Request) {
+	if s.BlockJSON {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	products := s.sorted()
+
+	if v := q.Get("updated_at_min"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid updated_at_min", http.StatusBadRequest)
+			return
+		}
+		var kept []models.ShopifyProduct
+		for _, p := range products {
+			if !p.UpdatedAt.Before(since) {
+				kept = append(kept, p)
+			}
+		}
+		products = kept
+	}
+
+	limit := maxPageSize
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v < limit {
+		limit = v
+	}
+	if s.PageSize > 0 && s.PageSize < limit {
+		limit = s.PageSize
+	}
+
+	offset := 0
+	if s.LinkPaging {
+		offset, _ = strconv.Atoi(q.Get("page_info"))
+	} else if sinceID, err := strconv.ParseInt(q.Get("since_id"), 10, 64); err == nil {
+		offset = sort.Search(len(products), func(i int) bool { return products[i].ID > sinceID })
+	}
+	offset = min(offset, len(products))
+	end := min(offset+limit, len(products))
+
+	if s.LinkPaging && end < len(products) {
+		next := fmt.Sprintf("/products.json?limit=%d&page_info=%d", limit, end)
+		if v := q.Get("updated_at_min"); v != "" {
+			next += "&updated_at_min=" + v
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<https://%s%s>; rel="next"`, r.Host, next))
+	}
+
+	writeJSON(w, models.ShopifyProductsResponse{Products: products[offset:end]})
+}
+
+// serveProduct serves a product page, with the schema.org JSON-LD themes embed, or its
+// .js endpoint with prices in cents
+func (s *Store) serveProduct(w http.ResponseWriter, r *http.Request) {
+	handle, js := strings.CutSuffix(r.PathValue("handle"), ".js")
+	p, ok := s.product(handle)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if js {
+		writeJSON(w, productJS(p))
+		return
+	}
+
+	data, err := json.Marshal(s.productJSONLD(r, p))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<html><head><title>%s</title>`+
+		`<script type="application/ld+json">%s</script></head><body><h1>%s</h1></body></html>`,
+		template.HTMLEscapeString(p.Title), data, template.HTMLEscapeString(p.Title))
+}
+
+// productJSONLD renders a product the way Shopify themes do: no product ID, one offer per
+// variant linking to it with ?variant=
+func (s *Store) productJSONLD(r *http.Request, p models.ShopifyProduct) map[string]interface{} {
+	offers := make([]map[string]interface{}, len(p.Variants))
+	for i, v := range p.Variants {
+		availability := "https://schema.org/OutOfStock"
+		if v.Available {
+			availability = "https://schema.org/InStock"
+		}
+		offers[i] = map[string]interface{}{
+			"@type":         "Offer",
+			"name":          v.Title,
+			"sku":           v.SKU,
+			"price":         v.Price,
+			"priceCurrency": s.Currency,
+			"availability":  availability,
+			"url":           fmt.Sprintf("https://%s/products/%s?variant=%d", r.Host, p.Handle, v.ID),
+		}
+	}
+
+	images := make([]string, len(p.Images))
+	for i, img := range p.Images {
+		images[i] = img.Src
+	}
+
+	return map[string]interface{}{
+		"@context":    "http://schema.org/",
+		"@type":       "Product",
+		"name":        p.Title,
+		"url":         fmt.Sprintf("https://%s/products/%s", r.Host, p.Handle),
+		"description": p.BodyHTML,
+		"brand":       map[string]interface{}{"@type": "Brand", "name": p.Vendor},
+		"image":       images,
+		"offers":      offers,
+	}
+}
+
+// serveCollection lists the catalog on pages of 24 products, empty past the last one
+func (s *Store) serveCollection(w http.ResponseWriter, r *http.Request) {
+	const perPage = 24
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	products := s.sorted()
+	start := min((page-1)*perPage, len(products))
+	end := min(start+perPage, len(products))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<html><body><div class="collection">`)
+	for _, p := range products[start:end] {
+		fmt.Fprintf(w, `<div class="product-card"><a href="/products/%s">%s</a></div>`,
+			p.Handle, template.HTMLEscapeString(p.Title))
+	}
+	fmt.Fprint(w, `</div></body></html>`)
+}
+
+func (s *Store) serveSitemapIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+
+		`<sitemap><loc>https://%s/sitemap_products_1.xml</loc></sitemap></sitemapindex>`, r.Host)
+}
+
+func (s *Store) serveProductSitemap(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, p := range s.sorted() {
+		fmt.Fprintf(w, `<url><loc>https://%s/products/%s</loc><lastmod>%s</lastmod></url>`,
+			r.Host, p.Handle, p.UpdatedAt.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprint(w, `</urlset>`)
+}
+
+func (s *Store) serveMeta(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"name": s.Name, "currency": s.Currency})
+}
+
+func (s *Store) servePassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<html><body><form action="/password" method="post"><input type="password" name="password"></form></body></html>`)
+}
+
+// sorted returns the catalog in ID order, as products.json serves it
+func (s *Store) sorted() []models.ShopifyProduct {
+	products := append([]models.ShopifyProduct(nil), s.Products...)
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products
+}
+
+func (s *Store) product(handle string) (models.ShopifyProduct, bool) {
+	for _, p := range s.Products {
+		if p.Handle == handle {
+			return p, true
+		}
+	}
+	return models.ShopifyProduct{}, false
+}
+
+// productJS renders a product the way /products/{handle}.js does: prices in cents, tags and
+// images as plain lists
+func productJS(p models.ShopifyProduct) map[string]interface{} {
+	variants := make([]map[string]interface{}, len(p.Variants))
+	for i, v := range p.Variants {
+		jv := map[string]interface{}{
+			"id":                 v.ID,
+			"title":              v.Title,
+			"sku":                v.SKU,
+			"option1":            v.Option1,
+			"available":          v.Available,
+			"price":              cents(v.Price),
+			"compare_at_price":   nil,
+			"inventory_quantity": v.InventoryQuantity,
+		}
+		if v.CompareAtPrice != nil {
+			jv["compare_at_price"] = cents(*v.CompareAtPrice)
+		}
+		variants[i] = jv
+	}
+
+	images := make([]string, len(p.Images))
+	for i, img := range p.Images {
+		images[i] = strings.TrimPrefix(img.Src, "https:")
+	}
+
+	tags, _ := p.Tags.([]string)
+	if s, ok := p.Tags.(string); ok && s != "" {
+		tags = strings.Split(s, ", ")
+	}
+
+	return map[string]interface{}{
+		"id":           p.ID,
+		"title":        p.Title,
+		"handle":       p.Handle,
+		"description":  p.BodyHTML,
+		"vendor":       p.Vendor,
+		"type":         p.ProductType,
+		"tags":         tags,
+		"images":       images,
+		"published_at": p.PublishedAt,
+		"created_at":   p.CreatedAt,
+		"variants":     variants,
+	}
+}
+
+// cents converts a decimal price such as "12.50" to 1250
+func cents(price string) int64 {
+	f, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(f*100 + 0.5)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,173 @@
+package httpfixture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Modes of a Transport
+const (
+	ModeRecord = "record" // Requests go to the network and responses are saved
+	ModeReplay = "replay" // Responses are served from disk, the network is never used
+)
+
+// ErrNoFixture is returned in replay mode for a request that was never recorded
+var ErrNoFixture = errors.New("no fixture recorded")
+
+// Transport is an http.RoundTripper that records real responses as fixture files, or
+// replays them from disk so the scraper can run offline and deterministically. Fixtures
+// are keyed by method and URL, one JSON file per request under a directory per host.
+type Transport struct {
+	dir  string
+	mode string
+	next http.RoundTripper
+}
+
+// fixture is a recorded response as stored on disk
+type fixture struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+	Base64     bool        `json:"base64,omitempty"` // Body is base64, for responses that aren't UTF-8 text
+}
+
+// NewTransport creates a transport reading or writing fixtures in dir. In record mode
+// requests are sent through next, http.DefaultTransport when nil.
+func NewTransport(dir, mode string, next http.RoundTripper) (*Transport, error) {
+	if mode != ModeRecord && mode != ModeReplay {
+		return nil, fmt.Errorf("unknown fixture mode %q", mode)
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("fixture directory is required")
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Transport{dir: dir, mode: mode, next: next}, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.mode == ModeReplay {
+		return t.replay(req)
+	}
+	return t.record(req)
+}
+
+// record sends the request and saves its response before handing it back
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	f := fixture{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       string(body),
+	}
+	if !utf8.Valid(body) {
+		f.Body = base64.StdEncoding.EncodeToString(body)
+		f.Base64 = true
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	path := t.path(req)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return resp, nil
+}
+
+// replay serves the response recorded for the request
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	data, err := os.ReadFile(t.path(req))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrNoFixture)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to decode fixture: %w", err)
+	}
+
+	body := []byte(f.Body)
+	if f.Base64 {
+		if body, err = base64.StdEncoding.DecodeString(f.Body); err != nil {
+			return nil, fmt.Errorf("failed to decode fixture body: %w", err)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.StatusCode, http.StatusText(f.StatusCode)),
+		StatusCode:    f.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        f.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// path returns where the fixture of a request lives: <dir>/<host>/<path>-<hash>.json,
+// readable enough to find by hand and unique per method and URL
+func (t *Transport) path(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+
+	name := strings.Trim(req.URL.Path, "/")
+	name = strings.NewReplacer("/", "_", ".", "_").Replace(name)
+	if name == "" {
+		name = "index"
+	}
+	if len(name) > 80 {
+		name = name[:80]
+	}
+
+	return filepath.Join(t.dir, sanitizeHost(req.URL.Host), name+"-"+hex.EncodeToString(sum[:6])+".json")
+}
+
+// sanitizeHost makes a host usable as a directory name
+func sanitizeHost(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ':' || r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, host)
+}
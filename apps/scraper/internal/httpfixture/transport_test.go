@@ -0,0 +1,70 @@
+package httpfixture
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// get fetches url through rt, returning the status, content type and body
+func get(t *testing.T, rt http.RoundTripper, url string) (int, string, string, error) {
+	t.Helper()
+
+	resp, err := (&http.Client{Transport: rt}).Get(url)
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", url, err)
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), string(body), nil
+}
+
+func TestTransportRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore("Shop", Catalog(5, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	urls := []string{
+		"https://shop.example/products.json?limit=250&since_id=0",
+		"https://shop.example/products/product-3",
+		"https://shop.example/robots.txt",
+	}
+
+	recorder, err := NewTransport(dir, ModeRecord, store.Transport())
+	if err != nil {
+		t.Fatalf("NewTransport: %v", err)
+	}
+	replayer, err := NewTransport(dir, ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("NewTransport: %v", err)
+	}
+
+	for _, url := range urls {
+		status, contentType, body, err := get(t, recorder, url)
+		if err != nil {
+			t.Fatalf("record %s: %v", url, err)
+		}
+
+		gotStatus, gotContentType, gotBody, err := get(t, replayer, url)
+		if err != nil {
+			t.Fatalf("replay %s: %v", url, err)
+		}
+		if gotStatus != status || gotContentType != contentType || gotBody != body {
+			t.Errorf("replay %s = %d %q %q, recorded %d %q %q",
+				url, gotStatus, gotContentType, gotBody, status, contentType, body)
+		}
+	}
+
+	if n := store.Requests("/products.json"); n != 1 {
+		t.Errorf("replay reached the store: %d products.json requests", n)
+	}
+
+	_, _, _, err = get(t, replayer, "https://shop.example/products.json?limit=250&since_id=5")
+	if !errors.Is(err, ErrNoFixture) {
+		t.Fatalf("got %v for an unrecorded URL, want ErrNoFixture", err)
+	}
+}
@@ -3,6 +3,8 @@ package parser
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"indie-marketplace/scraper/pkg/models"
@@ -29,24 +31,8 @@ func (p *HTMLParser) ParseProductPage(html string) (*models.ShopifyProduct, erro
 	// Try to find JSON-LD data first (most reliable)
 	var product *models.ShopifyProduct
 
-	doc.Find("script[type='application/ld+json']").Each(func(i int, s *goquery.Selection) {
-		if product != nil {
-			return
-		}
-
-		var data map[string]interface{}
-		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
-			return
-		}
-
-		// Check if this is a Product schema
-		if data["@type"] == "Product" {
-			product = p.parseJSONLD(data)
-		}
-	})
-
-	if product != nil {
-		return product, nil
+	if data := productJSONLD(doc); data != nil {
+		return p.parseJSONLD(data), nil
 	}
 
 	// Fallback: try to find the Shopify product JSON in a script tag
@@ -101,15 +87,27 @@ func (p *HTMLParser) parseJSONLD(data map[string]interface{}) *models.ShopifyPro
 		}
 	}
 
-	// Parse offers
-	if offers, ok := data["offers"].([]interface{}); ok && len(offers) > 0 {
+	// Parse offers, given as a list or as a single Offer or AggregateOffer
+	offers, _ := data["offers"].([]interface{})
+	if offer, ok := data["offers"].(map[string]interface{}); ok {
+		if nested, ok := offer["offers"].([]interface{}); ok {
+			offers = nested
+		} else {
+			offers = []interface{}{offer}
+		}
+	}
+	if len(offers) > 0 {
 		for _, offer := range offers {
 			if o, ok := offer.(map[string]interface{}); ok {
 				variant := models.ShopifyVariant{}
 
-				if price, ok := o["price"].(string); ok {
+				amount := o["price"]
+				if amount == nil {
+					amount = o["lowPrice"]
+				}
+				if price, ok := amount.(string); ok {
 					variant.Price = price
-				} else if price, ok := o["price"].(float64); ok {
+				} else if price, ok := amount.(float64); ok {
 					variant.Price = fmt.Sprintf("%.2f", price)
 				}
 
@@ -117,6 +115,12 @@ func (p *HTMLParser) parseJSONLD(data map[string]interface{}) *models.ShopifyPro
 					variant.SKU = sku
 				}
 
+				// Shopify themes link each offer to its variant with ?variant=<id>
+				variant.ID = offerVariantID(o)
+				if name, ok := o["name"].(string); ok {
+					variant.Title = name
+				}
+
 				if availability, ok := o["availability"].(string); ok {
 					variant.Available = strings.Contains(availability, "InStock")
 				}
@@ -146,6 +150,23 @@ func (p *HTMLParser) parseJSONLD(data map[string]interface{}) *models.ShopifyPro
 	return product
 }
 
+// offerVariantID returns the variant ID in the ?variant= parameter of an offer's url, 0 when absent
+func offerVariantID(offer map[string]interface{}) int64 {
+	raw, ok := offer["url"].(string)
+	if !ok {
+		return 0
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return 0
+	}
+	id, err := strconv.ParseInt(u.Query().Get("variant"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0
+	}
+	return id
+}
+
 // extractJSONObject extracts a JSON object from a string starting at position 0
 func extractJSONObject(s string) string {
 	s = strings.TrimSpace(s)
@@ -215,17 +236,9 @@ func (p *HTMLParser) ParseCollectionPage(html string) ([]string, error) {
 			}
 
 			// Extract handle from URL
-			if strings.Contains(href, "/products/") {
-				parts := strings.Split(href, "/products/")
-				if len(parts) > 1 {
-					handle := strings.Split(parts[1], "?")[0]
-					handle = strings.Split(handle, "#")[0]
-					handle = strings.TrimSuffix(handle, "/")
-
-					if handle != "" && !contains(handles, handle) {
-						handles = append(handles, handle)
-					}
-				}
+			handle := ProductHandle(href)
+			if handle != "" && !contains(handles, handle) {
+				handles = append(handles, handle)
 			}
 		})
 
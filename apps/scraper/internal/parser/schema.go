@@ -0,0 +1,157 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"indie-marketplace/scraper/pkg/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// SchemaPage is what the crawler reads from a page of a site that isn't on Shopify
+type SchemaPage struct {
+	Product      *models.ShopifyProduct // Nil when the page has no schema.org Product
+	Currency     string                 // priceCurrency of the product's offers
+	CanonicalURL string                 // rel=canonical, else og:url, else the page URL
+	Links        []string               // Absolute http(s) links found on the page
+}
+
+// ParseSchemaPage extracts the schema.org Product JSON-LD, canonical URL and links of a page.
+// URLs are resolved against pageURL and returned without their fragment.
+func (p *HTMLParser) ParseSchemaPage(html string, pageURL *url.URL) (*SchemaPage, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	page := &SchemaPage{CanonicalURL: canonicalURL(doc, pageURL)}
+
+	if data := productJSONLD(doc); data != nil {
+		page.Product = p.parseJSONLD(data)
+		page.Currency = offerCurrency(data)
+	}
+
+	seen := make(map[string]bool)
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		link := resolve(pageURL, href)
+		if link != "" && !seen[link] {
+			seen[link] = true
+			page.Links = append(page.Links, link)
+		}
+	})
+
+	return page, nil
+}
+
+// productJSONLD returns the first schema.org Product among the page's JSON-LD blocks,
+// looking inside top-level arrays and @graph containers
+func productJSONLD(doc *goquery.Document) map[string]interface{} {
+	var product map[string]interface{}
+
+	doc.Find("script[type='application/ld+json']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		product = findProduct(data)
+		return product == nil
+	})
+
+	return product
+}
+
+func findProduct(v interface{}) map[string]interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, item := range t {
+			if p := findProduct(item); p != nil {
+				return p
+			}
+		}
+	case map[string]interface{}:
+		if isType(t["@type"], "Product") {
+			return t
+		}
+		if graph, ok := t["@graph"]; ok {
+			return findProduct(graph)
+		}
+	}
+	return nil
+}
+
+// isType reports whether a JSON-LD @type, a string or a list of strings, includes name
+func isType(v interface{}, name string) bool {
+	switch t := v.(type) {
+	case string:
+		return t == name
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && s == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// offerCurrency returns the priceCurrency of the first offer that has one
+func offerCurrency(data map[string]interface{}) string {
+	var offers []interface{}
+	switch o := data["offers"].(type) {
+	case []interface{}:
+		offers = o
+	case map[string]interface{}:
+		offers = append([]interface{}{o}, toSlice(o["offers"])...)
+	}
+
+	for _, offer := range offers {
+		if o, ok := offer.(map[string]interface{}); ok {
+			if currency, ok := o["priceCurrency"].(string); ok && currency != "" {
+				return strings.ToUpper(currency)
+			}
+		}
+	}
+	return ""
+}
+
+func toSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
+
+// canonicalURL picks the URL a page declares as its own, falling back to pageURL
+func canonicalURL(doc *goquery.Document, pageURL *url.URL) string {
+	if href, ok := doc.Find("link[rel='canonical']").First().Attr("href"); ok {
+		if u := resolve(pageURL, href); u != "" {
+			return u
+		}
+	}
+	if content, ok := doc.Find("meta[property='og:url']").First().Attr("content"); ok {
+		if u := resolve(pageURL, content); u != "" {
+			return u
+		}
+	}
+	return resolve(pageURL, pageURL.String())
+}
+
+// resolve makes href absolute against base and drops its fragment,
+// returning "" for anything that isn't an http(s) URL
+func resolve(base *url.URL, href string) string {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+
+	u := base.ResolveReference(ref)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String()
+}
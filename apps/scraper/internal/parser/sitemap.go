@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SitemapEntry is a <sitemap> or <url> element of a sitemap
+type SitemapEntry struct {
+	Loc     string
+	LastMod time.Time // Zero when the sitemap omits or garbles lastmod
+}
+
+// Sitemap is a parsed sitemap document. An index only has Sitemaps,
+// a urlset only has URLs.
+type Sitemap struct {
+	Sitemaps []SitemapEntry
+	URLs     []SitemapEntry
+}
+
+type sitemapXML struct {
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+}
+
+// ParseSitemap parses a sitemap index or urlset
+func ParseSitemap(data []byte) (*Sitemap, error) {
+	var doc sitemapXML
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap: %w", err)
+	}
+
+	sitemap := &Sitemap{}
+	for _, s := range doc.Sitemaps {
+		sitemap.Sitemaps = append(sitemap.Sitemaps, SitemapEntry{
+			Loc:     strings.TrimSpace(s.Loc),
+			LastMod: parseLastMod(s.LastMod),
+		})
+	}
+	for _, u := range doc.URLs {
+		sitemap.URLs = append(sitemap.URLs, SitemapEntry{
+			Loc:     strings.TrimSpace(u.Loc),
+			LastMod: parseLastMod(u.LastMod),
+		})
+	}
+
+	return sitemap, nil
+}
+
+// parseLastMod accepts the W3C datetime forms sitemaps use
+func parseLastMod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ProductHandle extracts the product handle from a storefront product URL,
+// or returns "" when the URL is not a product page
+func ProductHandle(rawURL string) string {
+	parts := strings.SplitN(rawURL, "/products/", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	handle := strings.Split(parts[1], "?")[0]
+	handle = strings.Split(handle, "#")[0]
+	return strings.Trim(handle, "/")
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// myshopifyDomain matches the permanent domain every Shopify store has
+var myshopifyDomain = regexp.MustCompile(`[a-z0-9][a-z0-9-]*\.myshopify\.com`)
+
+// StorefrontMeta is what a store's home page says about the brand behind it
+type StorefrontMeta struct {
+	SiteName        string // og:site_name
+	Title           string // <title>
+	Description     string // og:description, else the meta description
+	LogoURL         string // Organization logo from JSON-LD, else a header image named logo, else og:image
+	MyshopifyDomain string // Empty when the page doesn't mention it
+	ShopifyAssets   bool   // The page loads assets from cdn.shopify.com
+}
+
+// ParseStorefrontMeta reads the brand details of a home page. URLs are resolved against pageURL.
+func ParseStorefrontMeta(html string, pageURL *url.URL) (*StorefrontMeta, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	meta := &StorefrontMeta{
+		SiteName:        metaContent(doc, "meta[property='og:site_name']"),
+		Title:           strings.TrimSpace(doc.Find("title").First().Text()),
+		Description:     metaContent(doc, "meta[property='og:description']"),
+		MyshopifyDomain: myshopifyDomain.FindString(html),
+		ShopifyAssets:   strings.Contains(html, "cdn.shopify.com"),
+	}
+	if meta.Description == "" {
+		meta.Description = metaContent(doc, "meta[name='description']")
+	}
+
+	logo := organizationLogo(doc)
+	if logo == "" {
+		logo, _ = doc.Find("header img[class*='logo'], img[class*='logo']").First().Attr("src")
+	}
+	if logo == "" {
+		logo = metaContent(doc, "meta[property='og:image']")
+	}
+	if logo != "" {
+		meta.LogoURL = resolve(pageURL, logo)
+	}
+
+	return meta, nil
+}
+
+func metaContent(doc *goquery.Document, selector string) string {
+	content, _ := doc.Find(selector).First().Attr("content")
+	return strings.TrimSpace(content)
+}
+
+// organizationLogo returns the logo of the first schema.org Organization in the page's JSON-LD
+func organizationLogo(doc *goquery.Document) string {
+	var logo string
+
+	doc.Find("script[type='application/ld+json']").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		if !isType(data["@type"], "Organization") {
+			return true
+		}
+
+		switch l := data["logo"].(type) {
+		case string:
+			logo = l
+		case map[string]interface{}:
+			logo, _ = l["url"].(string)
+		}
+		return logo == ""
+	})
+
+	return logo
+}
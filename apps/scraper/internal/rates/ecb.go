@@ -0,0 +1,84 @@
+package rates
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// ecbEnvelope mirrors the ECB euro foreign exchange reference rates XML,
+// both the daily file and the 90-day / historical ones
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string  `xml:"currency,attr"`
+			Rate     float64 `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ParseECB reads an ECB reference rates XML document (eurofxref-daily.xml,
+// eurofxref-hist-90d.xml or eurofxref-hist.xml)
+func ParseECB(r io.Reader) ([]models.ExchangeRate, error) {
+	var env ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, fmt.Errorf("failed to decode ECB XML: %w", err)
+	}
+
+	var rates []models.ExchangeRate
+	for _, day := range env.Days {
+		date, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate date %q: %w", day.Time, err)
+		}
+
+		for _, r := range day.Rates {
+			if r.Currency == "" || r.Rate <= 0 {
+				continue
+			}
+			rates = append(rates, models.ExchangeRate{
+				Currency: strings.ToUpper(r.Currency),
+				Date:     date,
+				Rate:     r.Rate,
+			})
+		}
+	}
+
+	return rates, nil
+}
+
+// LoadDir parses every .xml file dropped into dir. Files are read in name order,
+// so a later file wins when two of them carry the same currency and day.
+func LoadDir(dir string) ([]models.ExchangeRate, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rate files: %w", err)
+	}
+	sort.Strings(files)
+
+	var rates []models.ExchangeRate
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+
+		parsed, err := ParseECB(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		rates = append(rates, parsed...)
+	}
+
+	return rates, nil
+}
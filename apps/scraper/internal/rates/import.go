@@ -0,0 +1,25 @@
+package rates
+
+import (
+	"context"
+
+	"indie-marketplace/scraper/internal/storage"
+)
+
+// Import loads the rate files found in dir into the database and, when any rate
+// was added or changed, refreshes the EUR prices of every product.
+// It returns the number of rates written and products repriced.
+func Import(ctx context.Context, db *storage.DB, dir string) (int, int64, error) {
+	rates, err := LoadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	written, err := db.UpsertExchangeRates(ctx, rates)
+	if err != nil || written == 0 {
+		return written, 0, err
+	}
+
+	repriced, err := db.BackfillEURPrices(ctx)
+	return written, repriced, err
+}
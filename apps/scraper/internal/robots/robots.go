@@ -0,0 +1,177 @@
+package robots
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules are the robots.txt directives that apply to one user agent
+type Rules struct {
+	rules      []rule
+	crawlDelay time.Duration
+	sitemaps   []string
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// group is a run of user-agent lines and the directives that follow them
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+	directives bool // A directive line was read, so the next user-agent line starts a new group
+}
+
+// AllowAll returns rules that permit everything, used when a site has no robots.txt
+func AllowAll() *Rules {
+	return &Rules{}
+}
+
+// Parse reads a robots.txt file and keeps the group that applies to userAgent: every group
+// naming its product token (the part before the first "/"), else the "*" groups
+func Parse(data, userAgent string) *Rules {
+	token := strings.ToLower(strings.TrimSpace(strings.SplitN(userAgent, "/", 2)[0]))
+
+	r := &Rules{}
+	var groups []*group
+	var current *group
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share one group
+			if current == nil || current.directives {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			// An empty Disallow allows everything, which is the default anyway,
+			// but still ends the group's user-agent lines
+			if current != nil {
+				current.directives = true
+				if value != "" {
+					current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+				}
+			}
+		case "crawl-delay":
+			if current != nil {
+				current.directives = true
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					current.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			if value != "" {
+				r.sitemaps = append(r.sitemaps, value)
+			}
+		}
+	}
+
+	matched := matchGroups(groups, token)
+	if len(matched) == 0 {
+		matched = matchGroups(groups, "*")
+	}
+	for _, g := range matched {
+		r.rules = append(r.rules, g.rules...)
+		if g.crawlDelay > r.crawlDelay {
+			r.crawlDelay = g.crawlDelay
+		}
+	}
+
+	return r
+}
+
+// matchGroups returns the groups that name the given agent
+func matchGroups(groups []*group, agent string) []*group {
+	var matched []*group
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == agent {
+				matched = append(matched, g)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// Allowed reports whether a path (with its query string) may be fetched.
+// The longest matching pattern wins, and Allow wins a tie.
+func (r *Rules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allowed := true
+	longest := -1
+	for _, rl := range r.rules {
+		if !match(rl.pattern, path) {
+			continue
+		}
+		if len(rl.pattern) > longest || (len(rl.pattern) == longest && rl.allow) {
+			longest = len(rl.pattern)
+			allowed = rl.allow
+		}
+	}
+
+	return allowed
+}
+
+// CrawlDelay returns the Crawl-delay asked for, 0 when there is none
+func (r *Rules) CrawlDelay() time.Duration {
+	return r.crawlDelay
+}
+
+// Sitemaps returns the sitemap URLs listed in the file
+func (r *Rules) Sitemaps() []string {
+	return r.sitemaps
+}
+
+// match reports whether path matches a robots.txt pattern, where "*" matches
+// any run of characters and a trailing "$" anchors the end of the path
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+
+	return !anchored || pos == len(path)
+}
@@ -0,0 +1,86 @@
+package robots
+
+import (
+	"testing"
+	"time"
+)
+
+const userAgent = "IndieMarketBot/1.0 (+https://indiemarket.com/bot)"
+
+func TestParseGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		path  string
+		allow bool
+	}{
+		{"no rules", "", "/products/a", true},
+		{"wildcard group", "User-agent: *\nDisallow: /cart", "/cart/add", false},
+		{"own group wins over wildcard", "User-agent: *\nDisallow: /\n\nUser-agent: IndieMarketBot\nDisallow: /admin", "/products/a", true},
+		{"empty disallow ends the group", "User-agent: IndieMarketBot\nDisallow:\n\nUser-agent: *\nDisallow: /", "/products/a", true},
+		{"consecutive agents share a group", "User-agent: OtherBot\nUser-agent: IndieMarketBot\nDisallow: /products", "/products/a", false},
+		{"other agent's group", "User-agent: OtherBot\nDisallow: /", "/products/a", true},
+		{"comments", "User-agent: * # everyone\nDisallow: /private # keep out", "/private/x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.data, userAgent).Allowed(tt.path); got != tt.allow {
+				t.Fatalf("Allowed(%q) = %v, want %v", tt.path, got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	rules := Parse(`User-agent: *
+Disallow: /collections/*?*filter
+Disallow: /search
+Allow: /search/products$
+Disallow: /*.pdf$
+Disallow: /account
+Allow: /account/login
+`, userAgent)
+
+	tests := []struct {
+		path  string
+		allow bool
+	}{
+		{"/", true},
+		{"/robots.txt", true},
+		{"/collections/all", true},
+		{"/collections/all?page=2&filter.v.price=1", false},
+		{"/search?q=shirt", false},
+		{"/search/products", true},
+		{"/search/products/extra", false},
+		{"/files/catalog.pdf", false},
+		{"/files/catalog.pdf?v=2", true},
+		{"/account/orders", false},
+		{"/account/login", true},
+	}
+
+	for _, tt := range tests {
+		if got := rules.Allowed(tt.path); got != tt.allow {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.allow)
+		}
+	}
+}
+
+func TestCrawlDelayAndSitemaps(t *testing.T) {
+	rules := Parse(`Sitemap: https://shop.example/sitemap.xml
+User-agent: *
+Crawl-delay: 2
+
+User-agent: IndieMarketBot
+Crawl-delay: 0.5
+Disallow: /cart
+Sitemap: https://shop.example/sitemap_products_1.xml
+`, userAgent)
+
+	if got := rules.CrawlDelay(); got != 500*time.Millisecond {
+		t.Errorf("CrawlDelay() = %v, want 500ms", got)
+	}
+	if got := rules.Sitemaps(); len(got) != 2 {
+		t.Errorf("Sitemaps() = %v, want both sitemaps", got)
+	}
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// adaptWindow is how many recent syncs a brand's interval is learnt from
+const adaptWindow = 10
+
+// minAdaptRuns is how many syncs at the current interval are needed before it changes again
+const minAdaptRuns = 3
+
+// adaptInterval learns how often a brand changes from its recent syncs: the interval
+// doubles while syncs find nothing new and halves when most of them find changes, within
+// the configured bounds. Only syncs run since the last change count, so each interval gets
+// a fair trial. Brands with a schedule of their own keep it.
+func (s *Scheduler) adaptInterval(ctx context.Context, brand models.Brand) {
+	if s.maxInterval <= 0 || (brand.SyncSchedule != nil && *brand.SyncSchedule != "") {
+		return
+	}
+
+	var since time.Time
+	if brand.SyncIntervalSetAt != nil {
+		since = *brand.SyncIntervalSetAt
+	}
+
+	runs, err := s.db.RecentSyncActivity(ctx, brand.ID, since, adaptWindow)
+	if err != nil {
+		s.logger.Errorf("Failed to load sync activity for %s: %v", brand.Name, err)
+		return
+	}
+	if len(runs) < minAdaptRuns {
+		return
+	}
+
+	var current time.Duration
+	if brand.SyncInterval != nil {
+		current = time.Duration(*brand.SyncInterval) * time.Second
+	}
+
+	interval, reason := nextInterval(current, runs, s.minInterval, s.maxInterval)
+	if interval == current {
+		return
+	}
+
+	if err := s.db.UpdateBrandSyncInterval(ctx, brand.ID, interval, reason); err != nil {
+		s.logger.Errorf("Failed to update sync interval for %s: %v", brand.Name, err)
+		return
+	}
+	s.logger.Infof("Sync interval of %s is now %s: %s", brand.Name, interval, reason)
+}
+
+// nextInterval picks a brand's next interval from its recent syncs, newest first. A brand
+// without one yet starts from the spacing of those syncs.
+func nextInterval(current time.Duration, runs []models.SyncActivity, minInterval, maxInterval time.Duration) (time.Duration, string) {
+	changed, created := 0, 0
+	for _, r := range runs {
+		if r.Created+r.Updated+r.Removed > 0 {
+			changed++
+		}
+		created += r.Created
+	}
+
+	if current <= 0 {
+		current = runs[0].StartedAt.Sub(runs[len(runs)-1].StartedAt) / time.Duration(len(runs)-1)
+	}
+
+	next := current
+	var reason string
+	switch {
+	case changed == 0:
+		next = current * 2
+		reason = fmt.Sprintf("no changes in the last %d syncs", len(runs))
+	case changed*2 >= len(runs):
+		next = current / 2
+		reason = fmt.Sprintf("changes in %d of the last %d syncs, %d new products", changed, len(runs), created)
+	default:
+		reason = fmt.Sprintf("changes in %d of the last %d syncs", changed, len(runs))
+	}
+
+	next = next.Round(time.Minute)
+	if next < minInterval {
+		next = minInterval
+	}
+	if next > maxInterval {
+		next = maxInterval
+	}
+
+	return next, reason
+}
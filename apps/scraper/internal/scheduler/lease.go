@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// leaderLease is the lease held by the replica running the schedule
+const leaderLease = "scheduler"
+
+// ErrBrandClaimed is the error of a sync skipped because another replica is syncing the brand
+var ErrBrandClaimed = errors.New("brand is being synced by another replica")
+
+// brandLease names the lease a replica holds while syncing a brand
+func brandLease(brandID string) string {
+	return "brand:" + brandID
+}
+
+// defaultReplicaID identifies this process among the scraper replicas
+func defaultReplicaID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "scraper"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// lead competes for the scheduler lease until stop is closed or ctx is cancelled. Every
+// replica keeps its cron entries, but only the leader's queue brands; a replica taking over
+// also queues every brand, as a freshly started one would.
+func (s *Scheduler) lead(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		renewCtx, cancel := context.WithTimeout(ctx, s.leaseTTL/3)
+		leader, err := s.db.AcquireLease(renewCtx, leaderLease, s.replicaID, s.leaseTTL)
+		cancel()
+		if err != nil {
+			// Keep leading until the lease would have expired; others can't take it before
+			s.logger.Errorf("Failed to renew scheduler lease: %v", err)
+			leader = s.leader.Load() && time.Since(s.ledAt) < s.leaseTTL
+		}
+
+		switch {
+		case leader && !s.leader.Load():
+			s.logger.Infof("Replica %s is now running the schedule", s.replicaID)
+			s.leader.Store(true)
+			go s.syncAll()
+		case !leader && s.leader.Load():
+			s.logger.Warnf("Replica %s lost the scheduler lease", s.replicaID)
+			s.leader.Store(false)
+		}
+		if leader && err == nil {
+			s.ledAt = time.Now()
+		}
+
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		case <-ticker.C:
+			continue
+		}
+
+		if s.leader.Load() {
+			s.leader.Store(false)
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			if err := s.db.ReleaseLease(releaseCtx, leaderLease, s.replicaID); err != nil {
+				s.logger.Errorf("Failed to release scheduler lease: %v", err)
+			}
+			cancel()
+		}
+		return
+	}
+}
+
+// claimBrand takes a brand's lease and keeps renewing it until the returned release func
+// is called. The returned context is cancelled if the lease is lost to another replica, or
+// once renewals kept failing for a whole TTL, after which another replica may have taken it.
+func (s *Scheduler) claimBrand(ctx context.Context, brandID string) (context.Context, func(), error) {
+	name := brandLease(brandID)
+
+	// The lease runs from the moment it was asked for, not from when the answer came back
+	renewedAt := time.Now()
+	ok, err := s.db.AcquireLease(ctx, name, s.replicaID, s.leaseTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrBrandClaimed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(s.leaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			attemptedAt := time.Now()
+			renewCtx, renewCancel := context.WithTimeout(ctx, s.leaseTTL/3)
+			ok, err := s.db.AcquireLease(renewCtx, name, s.replicaID, s.leaseTTL)
+			renewCancel()
+			if err != nil {
+				if time.Since(renewedAt) >= s.leaseTTL {
+					s.logger.Warnf("Lease %s expired after failed renewals, stopping the sync: %v", name, err)
+					cancel()
+					return
+				}
+				s.logger.Errorf("Failed to renew lease %s: %v", name, err)
+				continue
+			}
+			if !ok {
+				s.logger.Warnf("Lost lease %s to another replica, stopping the sync", name)
+				cancel()
+				return
+			}
+			renewedAt = attemptedAt
+		}
+	}()
+
+	release := func() {
+		close(done)
+		cancel()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := s.db.ReleaseLease(ctx, name, s.replicaID); err != nil {
+			s.logger.Errorf("Failed to release lease %s: %v", name, err)
+		}
+	}
+
+	return ctx, release, nil
+}
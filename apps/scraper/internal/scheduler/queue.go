@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// syncQueue holds the brands due for a sync, most urgent first. A brand is queued at
+// most once and not again while it is syncing, so a slow brand can't pile up runs.
+type syncQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	brands  brandHeap
+	queued  map[string]bool
+	running map[string]bool
+	paused  bool
+	closed  bool
+}
+
+func newSyncQueue() *syncQueue {
+	q := &syncQueue{
+		queued:  make(map[string]bool),
+		running: make(map[string]bool),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues a brand, reporting false when it is already queued or syncing
+func (q *syncQueue) push(brand models.Brand) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed || q.queued[brand.ID] || q.running[brand.ID] {
+		return false
+	}
+
+	q.queued[brand.ID] = true
+	heap.Push(&q.brands, brand)
+	q.cond.Signal()
+	return true
+}
+
+// pop waits for the most urgent brand and marks it as syncing until done is called.
+// It returns false once the queue is closed.
+func (q *syncQueue) pop() (models.Brand, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for (len(q.brands) == 0 || q.paused) && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return models.Brand{}, false
+	}
+
+	brand := heap.Pop(&q.brands).(models.Brand)
+	delete(q.queued, brand.ID)
+	q.running[brand.ID] = true
+	return brand, true
+}
+
+// done marks a popped brand's sync as finished
+func (q *syncQueue) done(brandID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, brandID)
+}
+
+// setPaused holds the queued brands back until the queue is resumed.
+// Brands keep being queued meanwhile.
+func (q *syncQueue) setPaused(paused bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.paused = paused
+	q.cond.Broadcast()
+}
+
+// snapshot returns the queued brands, most urgent first
+func (q *syncQueue) snapshot() []models.Brand {
+	q.mu.Lock()
+	brands := append([]models.Brand(nil), q.brands...)
+	q.mu.Unlock()
+
+	sortByUrgency(brands)
+	return brands
+}
+
+// close wakes the waiting workers and drops the brands still queued
+func (q *syncQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.brands = nil
+	q.cond.Broadcast()
+}
+
+// moreUrgent orders brands by priority, then staleness: never synced first,
+// then the ones synced longest ago
+func moreUrgent(a, b models.Brand) bool {
+	if a.SyncPriority != b.SyncPriority {
+		return a.SyncPriority > b.SyncPriority
+	}
+	switch {
+	case a.LastSyncedAt == nil:
+		return b.LastSyncedAt != nil
+	case b.LastSyncedAt == nil:
+		return false
+	default:
+		return a.LastSyncedAt.Before(*b.LastSyncedAt)
+	}
+}
+
+// sortByUrgency sorts brands most urgent first
+func sortByUrgency(brands []models.Brand) {
+	sort.SliceStable(brands, func(i, j int) bool {
+		return moreUrgent(brands[i], brands[j])
+	})
+}
+
+// brandHeap implements heap.Interface over moreUrgent
+type brandHeap []models.Brand
+
+func (h brandHeap) Len() int           { return len(h) }
+func (h brandHeap) Less(i, j int) bool { return moreUrgent(h[i], h[j]) }
+func (h brandHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *brandHeap) Push(x interface{}) {
+	*h = append(*h, x.(models.Brand))
+}
+
+func (h *brandHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	brand := old[n-1]
+	*h = old[:n-1]
+	return brand
+}
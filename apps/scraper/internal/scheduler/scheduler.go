@@ -2,69 +2,371 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"indie-marketplace/scraper/internal/crawler"
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/rates"
 	"indie-marketplace/scraper/internal/shopify"
+	"indie-marketplace/scraper/internal/source"
 	"indie-marketplace/scraper/internal/storage"
+	"indie-marketplace/scraper/internal/woocommerce"
 	"indie-marketplace/scraper/pkg/models"
 
 	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
 )
 
+// Config holds the configuration for the scheduler
+type Config struct {
+	MaxWorkers       int           // Max concurrent brands being scraped
+	SyncInterval     string        // Cron expression for brands without a schedule of their own
+	MinSyncInterval  time.Duration // Shortest interval learnt for brands that change often
+	MaxSyncInterval  time.Duration // Longest interval learnt for dormant brands, learning is disabled when 0
+	FullSyncInterval time.Duration // How often a brand gets a full reconciliation pass instead of an incremental one
+	RatesDir         string        // Directory of ECB rate files imported before each run, disabled when empty
+	EnrichBudget     int           // Max /products/{handle}.js requests per brand and sync, disabled when 0
+	CrawlBudget      int           // Max pages the generic crawler fetches per brand and sync
+	MaxFailures      int           // Consecutive failed syncs before a brand is deactivated, never when 0
+	ReplicaID        string        // Identifies this replica in sync_leases
+	LeaseTTL         time.Duration // How long a lease outlives a replica that stopped renewing it
+	Fetcher          fetcher.Config
+}
+
+// DefaultConfig returns the default configuration
+func DefaultConfig() Config {
+	return Config{
+		MaxWorkers:       3,
+		SyncInterval:     "0 0 */6 * * *", // Every 6 hours
+		MinSyncInterval:  time.Hour,
+		MaxSyncInterval:  7 * 24 * time.Hour,
+		FullSyncInterval: 24 * time.Hour,
+		CrawlBudget:      500,
+		MaxFailures:      5,
+		ReplicaID:        defaultReplicaID(),
+		LeaseTTL:         2 * time.Minute,
+		Fetcher:          fetcher.DefaultConfig(),
+	}
+}
+
+// checkpointOverlap re-fetches a little before the stored checkpoint
+// to tolerate clock skew between the store and its CDN caches
+const checkpointOverlap = 5 * time.Minute
+
+// scheduleRefresh is how often brand schedules are re-read, so changes apply without a restart
+const scheduleRefresh = "@every 1m"
+
+// syncTimeout bounds the sync of a single brand
+const syncTimeout = 2 * time.Hour
+
+// minRetireShare is the smallest share of the live catalog a full pass must list for the
+// missing products to be retired. Listing far fewer more likely means a broken response
+// than a brand pulling most of its catalog at once.
+const minRetireShare = 0.5
+
+// defaultCurrency is assumed for stores whose currency could not be detected
+const defaultCurrency = "EUR"
+
 // Scheduler manages periodic scraping tasks
 type Scheduler struct {
 	db           *storage.DB
 	logger       *zap.SugaredLogger
 	cron         *cron.Cron
-	client       *shopify.Client
+	sources      map[string]source.Source // Keyed by brand platform
 	maxWorkers   int
 	syncInterval string
+	minInterval  time.Duration
+	maxInterval  time.Duration
+	fullSync     time.Duration
+	ratesDir     string
+	maxFailures  int
+	replicaID    string
+	leaseTTL     time.Duration
+
+	ctx     context.Context // Root context from Start, cancelled on shutdown
+	queue   *syncQueue
+	workers sync.WaitGroup // Workers and the leader loop
+	stop    chan struct{}
+	leader  atomic.Bool // This replica holds the scheduler lease and runs the schedule
+	ledAt   time.Time   // Last successful renewal of the scheduler lease
+	paused  atomic.Bool
+
+	progressMu sync.Mutex
+	progress   map[string]*BrandProgress // Syncs in progress, keyed by brand ID
+
+	mu      sync.Mutex
+	entries map[string]*scheduledBrand // Cron entry of each active brand, keyed by brand ID
+}
+
+// scheduledBrand is the cron entry that queues a brand for syncing
+type scheduledBrand struct {
+	entryID  cron.EntryID
+	schedule string       // Schedule the entry was registered for, as stored on the brand
+	brand    models.Brand // Latest copy, for ordering the queue
 }
 
-// New creates a new scheduler
-func New(db *storage.DB, logger *zap.SugaredLogger) *Scheduler {
+// New creates a new scheduler. All sources share one fetcher that rate-limits per store,
+// so raising MaxWorkers speeds up a full sync without hammering any one host.
+func New(db *storage.DB, logger *zap.SugaredLogger, config Config) *Scheduler {
+	if config.MaxWorkers < 1 {
+		config.MaxWorkers = 1
+	}
+	if config.LeaseTTL < 3*time.Second {
+		config.LeaseTTL = 3 * time.Second
+	}
+	if config.ReplicaID == "" {
+		config.ReplicaID = defaultReplicaID()
+	}
+
+	f := fetcher.New(config.Fetcher)
+
 	return &Scheduler{
-		db:           db,
-		logger:       logger,
-		cron:         cron.New(cron.WithSeconds()),
-		client:       shopify.NewClient(shopify.DefaultConfig()),
-		maxWorkers:   3, // Max concurrent brands being scraped
-		syncInterval: "0 0 */6 * * *", // Every 6 hours
+		db:     db,
+		logger: logger,
+		cron:   cron.New(cron.WithSeconds()),
+		sources: map[string]source.Source{
+			models.PlatformShopify:     source.NewShopify(shopify.NewClient(f), logger, config.EnrichBudget),
+			models.PlatformWooCommerce: source.NewWooCommerce(woocommerce.NewClient(f)),
+			models.PlatformGeneric:     source.NewGeneric(crawler.New(f), config.CrawlBudget),
+		},
+		maxWorkers:   config.MaxWorkers,
+		syncInterval: config.SyncInterval,
+		minInterval:  config.MinSyncInterval,
+		maxInterval:  config.MaxSyncInterval,
+		fullSync:     config.FullSyncInterval,
+		ratesDir:     config.RatesDir,
+		maxFailures:  config.MaxFailures,
+		replicaID:    config.ReplicaID,
+		leaseTTL:     config.LeaseTTL,
+		queue:        newSyncQueue(),
+		stop:         make(chan struct{}),
+		entries:      make(map[string]*scheduledBrand),
+		progress:     make(map[string]*BrandProgress),
 	}
 }
 
-// Start starts the scheduler
-func (s *Scheduler) Start() {
-	// Add the sync job
+// Start starts the scheduler. Each brand is queued on its own schedule, the default
+// SyncInterval unless it has one, and the workers sync the queued brands most urgent first.
+// With several replicas only the one holding the scheduler lease queues brands.
+// Cancelling ctx interrupts the syncs in progress; Stop then waits for them to wind down.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.ctx = ctx
+	s.sweepOrphanedLogs(ctx)
+
+	// Rates are imported on the default schedule, ahead of the brands synced on it
 	_, err := s.cron.AddFunc(s.syncInterval, func() {
-		s.runSync()
+		if !s.leader.Load() {
+			return
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, syncTimeout)
+		defer cancel()
+		s.importRates(ctx)
 	})
 	if err != nil {
 		s.logger.Errorf("Failed to add cron job: %v", err)
 		return
 	}
 
+	if _, err := s.cron.AddFunc(scheduleRefresh, s.refreshSchedules); err != nil {
+		s.logger.Errorf("Failed to add cron job: %v", err)
+		return
+	}
+
+	for i := 0; i < s.maxWorkers; i++ {
+		s.workers.Add(1)
+		go s.worker(i)
+	}
+
+	s.refreshSchedules()
 	s.cron.Start()
 
-	// Run initial sync
-	go s.runSync()
+	// The initial sync runs once this replica becomes the leader
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		s.lead(ctx, s.stop)
+	}()
 }
 
-// Stop stops the scheduler
+// Stop stops the scheduler, handing the schedule over to another replica. Syncs in
+// progress run to completion unless the context given to Start was cancelled.
 func (s *Scheduler) Stop() {
 	ctx := s.cron.Stop()
 	<-ctx.Done()
+
+	close(s.stop)
+	s.queue.close()
+	s.workers.Wait()
+}
+
+// syncAll imports the rates and queues every scheduled brand
+func (s *Scheduler) syncAll() {
+	ctx, cancel := context.WithTimeout(s.ctx, syncTimeout)
+	defer cancel()
+	s.importRates(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		s.queue.push(e.brand)
+	}
+}
+
+// refreshSchedules registers a cron entry for every active brand, replacing the ones
+// whose schedule changed and removing those of brands no longer active. It also picks up
+// a pause or resume made on another replica and, on the leader, sweeps the sync logs left
+// running by replicas that died since.
+func (s *Scheduler) refreshSchedules() {
+	ctx, cancel := context.WithTimeout(s.ctx, time.Minute)
+	defer cancel()
+
+	s.followPause(ctx)
+	if s.leader.Load() {
+		s.sweepOrphanedLogs(ctx)
+	}
+
+	brands, err := s.db.GetActiveBrands(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get active brands: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make(map[string]bool, len(brands))
+	for _, brand := range brands {
+		active[brand.ID] = true
+
+		schedule := s.scheduleFor(brand)
+		e, ok := s.entries[brand.ID]
+		if ok && e.schedule == schedule {
+			e.brand = brand
+			continue
+		}
+		if ok {
+			s.cron.Remove(e.entryID)
+		}
+
+		brandID := brand.ID
+		queue := func() { s.enqueue(brandID) }
+		entryID, err := s.cron.AddFunc(schedule, queue)
+		if err != nil {
+			s.logger.Warnf("Invalid sync schedule %q for %s, using %s: %v", schedule, brand.Name, s.syncInterval, err)
+			if entryID, err = s.cron.AddFunc(s.syncInterval, queue); err != nil {
+				s.logger.Errorf("Failed to add cron job for %s: %v", brand.Name, err)
+				delete(s.entries, brand.ID)
+				continue
+			}
+		}
+
+		s.entries[brand.ID] = &scheduledBrand{entryID: entryID, schedule: schedule, brand: brand}
+		if ok {
+			s.logger.Infof("Rescheduled %s: %s", brand.Name, schedule)
+		}
+	}
+
+	for id, e := range s.entries {
+		if !active[id] {
+			s.cron.Remove(e.entryID)
+			delete(s.entries, id)
+			s.logger.Infof("Unscheduled %s", e.brand.Name)
+		}
+	}
+}
+
+// scheduleFor returns the cron schedule of a brand: its own, else the interval learnt
+// from how often it changes, else the default
+func (s *Scheduler) scheduleFor(brand models.Brand) string {
+	switch {
+	case brand.SyncSchedule != nil && *brand.SyncSchedule != "":
+		return *brand.SyncSchedule
+	case brand.SyncInterval != nil && *brand.SyncInterval > 0 && s.maxInterval > 0:
+		return fmt.Sprintf("@every %s", time.Duration(*brand.SyncInterval)*time.Second)
+	default:
+		return s.syncInterval
+	}
+}
+
+// enqueue queues a scheduled brand for the workers, when this replica runs the schedule
+func (s *Scheduler) enqueue(brandID string) {
+	if !s.leader.Load() {
+		return
+	}
+
+	s.mu.Lock()
+	e, ok := s.entries[brandID]
+	var brand models.Brand
+	if ok {
+		brand = e.brand
+	}
+	s.mu.Unlock()
+
+	if ok && !s.queue.push(brand) {
+		s.logger.Infof("%s is still queued or syncing, skipping this run", brand.Name)
+	}
+}
+
+// worker syncs queued brands until the queue is closed
+func (s *Scheduler) worker(workerID int) {
+	defer s.workers.Done()
+
+	for {
+		queued, ok := s.queue.pop()
+		if !ok {
+			return
+		}
+		if s.ctx.Err() != nil {
+			// Shutting down: leave the rest of the queue to the next start
+			s.queue.done(queued.ID)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(s.ctx, syncTimeout)
+
+		// The brand may have moved on, or been deactivated, while it waited
+		brand, err := s.db.GetActiveBrand(ctx, queued.ID)
+		if err != nil {
+			s.logger.Errorf("Failed to get brand %s: %v", queued.Name, err)
+		} else if brand != nil {
+			s.logger.Infof("[Worker %d] Syncing brand: %s", workerID, brand.Name)
+			s.syncBrand(ctx, *brand)
+		}
+
+		cancel()
+		s.queue.done(queued.ID)
+	}
 }
 
-// runSync runs the synchronization for all active brands
-func (s *Scheduler) runSync() {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+// importRates picks up freshly dropped rate files so new prices convert with them
+func (s *Scheduler) importRates(ctx context.Context) {
+	if s.ratesDir == "" {
+		return
+	}
+
+	written, repriced, err := rates.Import(ctx, s.db, s.ratesDir)
+	if err != nil {
+		s.logger.Errorf("Failed to import exchange rates: %v", err)
+	} else if written > 0 {
+		s.logger.Infof("Imported %d exchange rates, repriced %d products", written, repriced)
+	}
+}
+
+// runSync runs the synchronization for all active brands, most urgent first
+func (s *Scheduler) runSync(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
 	defer cancel()
 
 	s.logger.Info("Starting sync for all brands")
 
+	s.sweepOrphanedLogs(ctx)
+
+	s.importRates(ctx)
+
 	brands, err := s.db.GetActiveBrands(ctx)
 	if err != nil {
 		s.logger.Errorf("Failed to get active brands: %v", err)
@@ -77,6 +379,7 @@ func (s *Scheduler) runSync() {
 	}
 
 	s.logger.Infof("Found %d active brands to sync", len(brands))
+	sortByUrgency(brands)
 
 	// Create a worker pool
 	jobs := make(chan models.Brand, len(brands))
@@ -90,6 +393,9 @@ func (s *Scheduler) runSync() {
 		go func(workerID int) {
 			defer wg.Done()
 			for brand := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
 				s.logger.Infof("[Worker %d] Syncing brand: %s", workerID, brand.Name)
 				result := s.syncBrand(ctx, brand)
 				results <- result
@@ -110,58 +416,193 @@ func (s *Scheduler) runSync() {
 	}()
 
 	// Collect results
-	var totalCreated, totalUpdated, totalFound int
-	var errors []string
+	var totalCreated, totalUpdated, totalUnchanged, totalFound, totalRemoved, skipped int
+	var failures []string
 
 	for result := range results {
 		totalFound += result.ProductsFound
 		totalCreated += result.ProductsCreated
 		totalUpdated += result.ProductsUpdated
-		if result.Error != nil {
-			errors = append(errors, result.Error.Error())
+		totalUnchanged += result.ProductsUnchanged
+		totalRemoved += result.ProductsRemoved
+		switch {
+		case errors.Is(result.Error, ErrBrandClaimed):
+			skipped++
+		case result.Error != nil:
+			failures = append(failures, result.Error.Error())
 		}
 	}
 
-	s.logger.Infof("Sync completed. Found: %d, Created: %d, Updated: %d, Errors: %d",
-		totalFound, totalCreated, totalUpdated, len(errors))
+	s.logger.Infof("Sync completed. Found: %d, Created: %d, Updated: %d, Unchanged: %d, Removed: %d, Errors: %d, "+
+		"Skipped: %d", totalFound, totalCreated, totalUpdated, totalUnchanged, totalRemoved, len(failures), skipped)
 }
 
-// syncBrand syncs a single brand
+// syncBrand syncs a single brand, unless another replica is already syncing it
 func (s *Scheduler) syncBrand(ctx context.Context, brand models.Brand) models.SyncResult {
 	result := models.SyncResult{BrandID: brand.ID}
 
+	ctx, release, err := s.claimBrand(ctx, brand.ID)
+	if err != nil {
+		result.Error = err
+		if errors.Is(err, ErrBrandClaimed) {
+			s.logger.Infof("Skipping %s: %v", brand.Name, err)
+		} else {
+			s.logger.Errorf("Failed to claim %s: %v", brand.Name, err)
+		}
+		return result
+	}
+	defer release()
+
+	s.startProgress(brand)
+	defer s.endProgress(brand.ID)
+
 	// Create sync log
 	logID, err := s.db.CreateSyncLog(ctx, brand.ID, "running")
 	if err != nil {
 		s.logger.Errorf("Failed to create sync log for %s: %v", brand.Name, err)
 	}
 
-	// Fetch products from Shopify
-	products, err := s.client.FetchProducts(ctx, brand.ShopifyDomain)
+	src, ok := s.sources[brand.Platform]
+	if !ok {
+		result.Error = fmt.Errorf("unsupported platform %q", brand.Platform)
+		s.logger.Errorf("Cannot sync %s: %v", brand.Name, result.Error)
+		s.finishSyncLog(ctx, logID, &result)
+		return result
+	}
+
+	// Fetch products, only the changed ones unless a full pass is due,
+	// picking up where an interrupted sync stopped
+	full := s.needsFullSync(brand)
+	var from source.Position
+	if !full && brand.SyncCheckpoint != nil {
+		from.Since = brand.SyncCheckpoint.Add(-checkpointOverlap)
+	}
+	resumed := brand.SyncCursor != nil
+	if resumed {
+		full = brand.SyncCursorFull
+		from = source.Position{Cursor: *brand.SyncCursor}
+		if brand.SyncCursorSince != nil {
+			from.Since = *brand.SyncCursorSince
+		}
+		s.logger.Infof("Resuming interrupted sync of %s", brand.Name)
+	}
+	result.Incremental = !full
+
+	var checkpoint time.Time
+	if brand.SyncCheckpoint != nil {
+		checkpoint = *brand.SyncCheckpoint
+	}
+
+	// Each page is written before the next one is fetched, so memory stays bounded by
+	// the page size and a failure on page 9 keeps pages 1-8
+	var currency string
+	var seen []int64
+	var upsertErr error
+	failed := 0
+	emitted := false
+
+	fetched, err := src.Fetch(ctx, brand, from, func(page source.Page) error {
+		emitted = true
+		if currency == "" {
+			currency = s.brandCurrency(ctx, src, brand, full, page.Currency)
+		}
+
+		bulk, err := s.db.UpsertProducts(ctx, brand.ID, currency, page.Products)
+		if err != nil {
+			upsertErr = err
+			return err
+		}
+
+		for _, f := range bulk.Failures {
+			s.logger.Errorf("Failed to upsert %v", f)
+		}
+
+		result.ProductsFound += len(page.Products)
+		result.ProductsCreated += bulk.Created
+		result.ProductsUpdated += bulk.Updated
+		result.ProductsUnchanged += bulk.Unchanged
+		result.ProductsRestored += bulk.Restored
+		result.PriceChanges += bulk.PriceChanges
+		result.VariantsChanged += bulk.VariantsChanged
+		result.ImagesChanged += bulk.ImagesChanged
+		failed += len(bulk.Failures) + page.Failed
+		s.trackPage(brand.ID, result)
+
+		for _, p := range page.Products {
+			seen = append(seen, p.ID)
+			if p.UpdatedAt.After(checkpoint) {
+				checkpoint = p.UpdatedAt
+			}
+		}
+
+		// Resume past this page only while every product made it in,
+		// otherwise the failed ones would be skipped
+		if page.Cursor != "" && failed == 0 {
+			if err := s.db.SaveSyncCursor(ctx, brand.ID, page.Cursor, from.Since, full); err != nil {
+				s.logger.Errorf("Failed to save sync cursor for %s: %v", brand.Name, err)
+			}
+		}
+		return nil
+	})
+	if fetcher.IsDisallowed(err) {
+		// Worth a word with the brand rather than a retry every run
+		result.Blocked = true
+		s.logger.Warnf("%s blocks us in its robots.txt: %v", brand.Name, err)
+	}
 	if err != nil {
 		result.Error = err
-		s.logger.Errorf("Failed to fetch products for %s: %v", brand.Name, err)
-		if logID != "" {
-			s.db.UpdateSyncLog(ctx, logID, result)
+		if upsertErr != nil {
+			// Our database failing is not the brand's fault
+			s.logger.Errorf("Failed to upsert products for %s: %v", brand.Name, err)
+		} else {
+			s.logger.Errorf("Failed to fetch products for %s: %v", brand.Name, err)
+			s.recordFailure(ctx, brand, err)
+		}
+
+		// A cursor that fails before yielding anything has probably gone stale
+		if resumed && !emitted && ctx.Err() == nil {
+			if err := s.db.ClearSyncCursor(ctx, brand.ID); err != nil {
+				s.logger.Errorf("Failed to clear sync cursor for %s: %v", brand.Name, err)
+			}
 		}
+		s.finishSyncLog(ctx, logID, &result)
 		return result
 	}
 
-	result.ProductsFound = len(products)
-	s.logger.Infof("Fetched %d products for %s", len(products), brand.Name)
+	if err := s.db.ClearSyncCursor(ctx, brand.ID); err != nil {
+		s.logger.Errorf("Failed to clear sync cursor for %s: %v", brand.Name, err)
+	}
 
-	// Upsert each product
-	for _, p := range products {
-		created, err := s.db.UpsertProduct(ctx, brand.ID, p)
-		if err != nil {
-			s.logger.Errorf("Failed to upsert product %s: %v", p.Title, err)
-			continue
+	// A pending brand only becomes active once its trial sync is accepted, by ActivateBrand
+	if brand.Status != models.BrandStatusPending && (brand.Status != models.BrandStatusActive || brand.Failures > 0) {
+		if err := s.db.RecordBrandSuccess(ctx, brand.ID); err != nil {
+			s.logger.Errorf("Failed to update status of %s: %v", brand.Name, err)
 		}
-		if created {
-			result.ProductsCreated++
-		} else {
-			result.ProductsUpdated++
+	}
+
+	result.Mode = fetched.Mode
+	result.ProductsEnriched = fetched.Enriched
+	s.logger.Infof("Fetched %d products for %s (incremental: %t, resumed: %t, mode: %s)",
+		result.ProductsFound, brand.Name, result.Incremental, resumed, result.Mode)
+
+	// A complete fetch or a sitemap lists every live product, so anything missing was pulled
+	// from the store. An empty catalog is more likely a broken response than a brand removing
+	// everything, and the collections crawl only sees what the theme links to. A resumed
+	// pass didn't see the pages before its cursor, so it cannot reconcile.
+	reconciled := full && !resumed
+	if reconciled {
+		var removed int
+		var err error
+		switch {
+		case fetched.Complete && len(seen) > 0 && s.canRetire(ctx, brand, len(seen)):
+			removed, err = s.db.RetireMissingProducts(ctx, brand.ID, seen)
+		case len(fetched.Listed) > 0 && s.canRetire(ctx, brand, len(fetched.Listed)):
+			removed, err = s.db.RetireMissingHandles(ctx, brand.ID, fetched.Listed)
+		}
+		if err != nil {
+			s.logger.Errorf("Failed to retire missing products for %s: %v", brand.Name, err)
 		}
+		result.ProductsRemoved = removed
 	}
 
 	// Update brand's last synced timestamp
@@ -169,17 +610,145 @@ func (s *Scheduler) syncBrand(ctx context.Context, brand models.Brand) models.Sy
 		s.logger.Errorf("Failed to update last synced at for %s: %v", brand.Name, err)
 	}
 
-	// Update sync log
-	if logID != "" {
-		s.db.UpdateSyncLog(ctx, logID, result)
+	// Only advance the checkpoint when every product made it in,
+	// otherwise the failed ones would be skipped until the next full pass
+	if failed == 0 && !checkpoint.IsZero() {
+		if err := s.db.UpdateBrandSyncCheckpoint(ctx, brand.ID, checkpoint); err != nil {
+			s.logger.Errorf("Failed to update sync checkpoint for %s: %v", brand.Name, err)
+		}
 	}
 
-	s.logger.Infof("Completed sync for %s. Created: %d, Updated: %d",
-		brand.Name, result.ProductsCreated, result.ProductsUpdated)
+	// Empty catalogs and storefronts without dates never get a checkpoint,
+	// so the full pass is recorded on its own
+	if reconciled {
+		if err := s.db.RecordFullSync(ctx, brand.ID); err != nil {
+			s.logger.Errorf("Failed to record full sync for %s: %v", brand.Name, err)
+		}
+	}
+
+	// Update sync log
+	s.finishSyncLog(ctx, logID, &result)
+
+	s.adaptInterval(ctx, brand)
+
+	s.logger.Infof("Completed sync for %s. Created: %d, Updated: %d, Unchanged: %d, Removed: %d, Restored: %d, "+
+		"Enriched: %d, Price changes: %d, Variant rows changed: %d, Image rows changed: %d",
+		brand.Name, result.ProductsCreated, result.ProductsUpdated, result.ProductsUnchanged,
+		result.ProductsRemoved, result.ProductsRestored, result.ProductsEnriched,
+		result.PriceChanges, result.VariantsChanged, result.ImagesChanged)
 
 	return result
 }
 
+// canRetire reports whether a full pass that listed the given number of products may retire
+// the brand's missing ones, refusing when that is well below the live products stored
+func (s *Scheduler) canRetire(ctx context.Context, brand models.Brand, listed int) bool {
+	live, err := s.db.CountLiveProducts(ctx, brand.ID)
+	if err != nil {
+		s.logger.Errorf("Failed to count live products of %s: %v", brand.Name, err)
+		return false
+	}
+
+	if float64(listed) < float64(live)*minRetireShare {
+		s.logger.Warnf("Not retiring products of %s: the store listed %d products but %d are live",
+			brand.Name, listed, live)
+		return false
+	}
+	return true
+}
+
+// finishSyncLog writes the outcome of a sync to its log. A sync cut short by shutdown is
+// logged as interrupted, and the log is written even though ctx was cancelled.
+func (s *Scheduler) finishSyncLog(ctx context.Context, logID string, result *models.SyncResult) {
+	if result.Error != nil && errors.Is(ctx.Err(), context.Canceled) {
+		result.Interrupted = true
+	}
+	if logID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := s.db.UpdateSyncLog(ctx, logID, *result); err != nil {
+		s.logger.Errorf("Failed to update sync log %s: %v", logID, err)
+	}
+}
+
+// sweepOrphanedLogs marks as interrupted the running sync logs no replica is working on,
+// left behind by processes that died mid-sync
+func (s *Scheduler) sweepOrphanedLogs(ctx context.Context) {
+	swept, err := s.db.SweepOrphanedSyncLogs(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to sweep orphaned sync logs: %v", err)
+		return
+	}
+	if swept > 0 {
+		s.logger.Infof("Marked %d orphaned sync logs as interrupted", swept)
+	}
+}
+
+// brandCurrency returns the currency a brand's store sells in. A currency the source saw
+// while fetching wins; otherwise it is re-detected on full passes and whenever it is unknown,
+// falling back to the stored value or defaultCurrency.
+func (s *Scheduler) brandCurrency(ctx context.Context, src source.Source, brand models.Brand, full bool, seen string) string {
+	currency := defaultCurrency
+	if brand.Currency != nil {
+		currency = *brand.Currency
+	}
+
+	detected := seen
+	if detected == "" {
+		if !full && brand.Currency != nil {
+			return currency
+		}
+
+		var err error
+		detected, err = src.Currency(ctx, brand)
+		if err != nil {
+			s.logger.Warnf("Failed to detect currency for %s, using %s: %v", brand.Name, currency, err)
+			return currency
+		}
+	}
+
+	if brand.Currency == nil || *brand.Currency != detected {
+		if err := s.db.UpdateBrandCurrency(ctx, brand.ID, detected); err != nil {
+			s.logger.Errorf("Failed to update currency for %s: %v", brand.Name, err)
+		}
+	}
+
+	return detected
+}
+
+// recordFailure classifies why fetching a brand failed and counts it against the brand,
+// which gets deactivated after too many failures in a row. Runs cut short by shutdown
+// are not the brand's fault and aren't counted, nor are the trial syncs of pending
+// brands, which stay pending so that adding them again retries.
+func (s *Scheduler) recordFailure(ctx context.Context, brand models.Brand, err error) {
+	if ctx.Err() != nil || brand.Status == models.BrandStatusPending {
+		return
+	}
+
+	reason := source.Classify(err)
+	status, dbErr := s.db.RecordBrandFailure(ctx, brand.ID, reason, err.Error(), s.maxFailures)
+	if dbErr != nil {
+		s.logger.Errorf("Failed to update status of %s: %v", brand.Name, dbErr)
+		return
+	}
+
+	if status == models.BrandStatusDeactivated {
+		s.logger.Warnf("Deactivated %s after %d consecutive failures (%s)", brand.Name, s.maxFailures, reason)
+	}
+}
+
+// needsFullSync reports whether a brand is due for a full reconciliation pass.
+// Brands without a checkpoint in between fetch everything, without reconciling.
+func (s *Scheduler) needsFullSync(brand models.Brand) bool {
+	if brand.LastFullSyncAt == nil {
+		return true
+	}
+	return time.Since(*brand.LastFullSyncAt) >= s.fullSync
+}
+
 // SyncBrandNow triggers an immediate sync for a specific brand
 func (s *Scheduler) SyncBrandNow(ctx context.Context, brandID string) (models.SyncResult, error) {
 	brands, err := s.db.GetActiveBrands(ctx)
@@ -196,7 +765,13 @@ func (s *Scheduler) SyncBrandNow(ctx context.Context, brandID string) (models.Sy
 	return models.SyncResult{}, nil
 }
 
-// RunSyncNow triggers an immediate sync for all brands (used for one-shot mode)
-func (s *Scheduler) RunSyncNow() {
-	s.runSync()
+// SyncBrand syncs a brand whether or not it is active yet, e.g. the trial sync of a new brand
+func (s *Scheduler) SyncBrand(ctx context.Context, brand models.Brand) models.SyncResult {
+	return s.syncBrand(ctx, brand)
+}
+
+// RunSyncNow triggers an immediate sync for all brands (used for one-shot mode).
+// Cancelling ctx interrupts it.
+func (s *Scheduler) RunSyncNow(ctx context.Context) {
+	s.runSync(ctx)
 }
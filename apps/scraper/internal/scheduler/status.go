@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// ErrUnknownBrand is returned when queueing a brand that doesn't exist or isn't active
+var ErrUnknownBrand = errors.New("no active brand with this ID")
+
+// Status is a snapshot of what the scheduler is doing
+type Status struct {
+	ReplicaID string          `json:"replica_id"`
+	Leader    bool            `json:"leader"`  // This replica runs the schedule
+	Paused    bool            `json:"paused"`  // Shared by every replica, as this one last read it
+	Queued    []QueuedBrand   `json:"queued"`  // Most urgent first
+	Running   []BrandProgress `json:"running"` // Oldest first
+}
+
+// QueuedBrand is a brand waiting for a worker
+type QueuedBrand struct {
+	BrandID      string     `json:"brand_id"`
+	BrandName    string     `json:"brand_name"`
+	Priority     int        `json:"sync_priority"`
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+}
+
+// BrandProgress is how far the sync of a brand has got
+type BrandProgress struct {
+	BrandID   string    `json:"brand_id"`
+	BrandName string    `json:"brand_name"`
+	StartedAt time.Time `json:"started_at"`
+	Pages     int       `json:"pages"` // Pages of products written so far
+	Found     int       `json:"products_found"`
+	Created   int       `json:"products_created"`
+	Updated   int       `json:"products_updated"`
+	Unchanged int       `json:"products_unchanged"`
+}
+
+// Status reports the queue and the syncs in progress
+func (s *Scheduler) Status() Status {
+	status := Status{
+		ReplicaID: s.replicaID,
+		Leader:    s.leader.Load(),
+		Paused:    s.paused.Load(),
+		Queued:    []QueuedBrand{},
+		Running:   []BrandProgress{},
+	}
+
+	for _, b := range s.queue.snapshot() {
+		status.Queued = append(status.Queued, QueuedBrand{
+			BrandID:      b.ID,
+			BrandName:    b.Name,
+			Priority:     b.SyncPriority,
+			LastSyncedAt: b.LastSyncedAt,
+		})
+	}
+
+	s.progressMu.Lock()
+	for _, p := range s.progress {
+		status.Running = append(status.Running, *p)
+	}
+	s.progressMu.Unlock()
+
+	sort.Slice(status.Running, func(i, j int) bool {
+		return status.Running[i].StartedAt.Before(status.Running[j].StartedAt)
+	})
+	return status
+}
+
+// Pause stops the workers of every replica from starting new syncs; the ones in progress
+// finish. The other replicas follow when they next refresh their schedules.
+func (s *Scheduler) Pause(ctx context.Context) error {
+	if err := s.db.SetPaused(ctx, true); err != nil {
+		return err
+	}
+	s.setPaused(true)
+	return nil
+}
+
+// Resume lets the workers of every replica pick up queued brands again
+func (s *Scheduler) Resume(ctx context.Context) error {
+	if err := s.db.SetPaused(ctx, false); err != nil {
+		return err
+	}
+	s.setPaused(false)
+	return nil
+}
+
+// followPause applies the pause state shared by the replicas
+func (s *Scheduler) followPause(ctx context.Context) {
+	paused, err := s.db.IsPaused(ctx)
+	if err != nil {
+		s.logger.Errorf("Failed to get pause state: %v", err)
+		return
+	}
+	s.setPaused(paused)
+}
+
+// setPaused holds the queue back or releases it on this replica
+func (s *Scheduler) setPaused(paused bool) {
+	if s.paused.Swap(paused) == paused {
+		return
+	}
+
+	s.queue.setPaused(paused)
+	if paused {
+		s.logger.Info("Scheduler paused")
+	} else {
+		s.logger.Info("Scheduler resumed")
+	}
+}
+
+// QueueBrand queues an active brand for syncing ahead of its schedule. It reports false
+// when the brand is already queued or syncing.
+func (s *Scheduler) QueueBrand(ctx context.Context, brandID string) (bool, error) {
+	brand, err := s.db.GetActiveBrand(ctx, brandID)
+	if err != nil {
+		return false, err
+	}
+	if brand == nil {
+		return false, ErrUnknownBrand
+	}
+
+	return s.queue.push(*brand), nil
+}
+
+// QueueAll queues every active brand and returns how many were not queued or syncing already
+func (s *Scheduler) QueueAll(ctx context.Context) (int, error) {
+	brands, err := s.db.GetActiveBrands(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, brand := range brands {
+		if s.queue.push(brand) {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// startProgress records that a brand's sync began
+func (s *Scheduler) startProgress(brand models.Brand) {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+
+	s.progress[brand.ID] = &BrandProgress{
+		BrandID:   brand.ID,
+		BrandName: brand.Name,
+		StartedAt: time.Now(),
+	}
+}
+
+// trackPage records a page written for a brand, with the run's totals so far
+func (s *Scheduler) trackPage(brandID string, result models.SyncResult) {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+
+	p, ok := s.progress[brandID]
+	if !ok {
+		return
+	}
+	p.Pages++
+	p.Found = result.ProductsFound
+	p.Created = result.ProductsCreated
+	p.Updated = result.ProductsUpdated
+	p.Unchanged = result.ProductsUnchanged
+}
+
+// endProgress forgets a finished sync
+func (s *Scheduler) endProgress(brandID string) {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+	delete(s.progress, brandID)
+}
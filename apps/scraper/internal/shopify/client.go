@@ -5,137 +5,178 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"indie-marketplace/scraper/internal/fetcher"
 	"indie-marketplace/scraper/pkg/models"
-
-	"golang.org/x/time/rate"
 )
 
-// Config holds the configuration for the Shopify client
-type Config struct {
-	UserAgent      string
-	RequestDelay   time.Duration
-	MaxRetries     int
-	RequestTimeout time.Duration
+// Client is a Shopify storefront client
+type Client struct {
+	fetcher *fetcher.Fetcher
 }
 
-// DefaultConfig returns the default configuration
-func DefaultConfig() Config {
-	return Config{
-		UserAgent:      "IndieMarketBot/1.0 (+https://indiemarket.com/bot)",
-		RequestDelay:   1 * time.Second,
-		MaxRetries:     3,
-		RequestTimeout: 30 * time.Second,
-	}
+// NewClient creates a new Shopify client on top of a shared fetcher
+func NewClient(f *fetcher.Fetcher) *Client {
+	return &Client{fetcher: f}
 }
 
-// Client is a Shopify API client
-type Client struct {
-	httpClient  *http.Client
-	rateLimiter *rate.Limiter
-	config      Config
+// FetchProducts fetches all products from a Shopify store.
+// It follows the Link header cursor when the store sends one and falls back
+// to since_id paging otherwise, stopping as soon as a page brings nothing new.
+func (c *Client) FetchProducts(ctx context.Context, domain string) ([]models.ShopifyProduct, error) {
+	return c.FetchProductsSince(ctx, domain, time.Time{})
 }
 
-// NewClient creates a new Shopify client
-func NewClient(config Config) *Client {
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: config.RequestTimeout,
-		},
-		// Allow 1 request per second with burst of 1
-		rateLimiter: rate.NewLimiter(rate.Every(config.RequestDelay), 1),
-		config:      config,
+// FetchProductsSince fetches the products updated after since, or every product when since is zero.
+// Stores that ignore updated_at_min still work: stale products are filtered out client-side.
+func (c *Client) FetchProductsSince(ctx context.Context, domain string, since time.Time) ([]models.ShopifyProduct, error) {
+	var allProducts []models.ShopifyProduct
+	_, err := c.FetchProductPages(ctx, domain, since, "", func(products []models.ShopifyProduct, next string) error {
+		allProducts = append(allProducts, products...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return allProducts, nil
 }
 
-// FetchProducts fetches all products from a Shopify store
-func (c *Client) FetchProducts(ctx context.Context, domain string) ([]models.ShopifyProduct, error) {
-	var allProducts []models.ShopifyProduct
-	page := 1
+// FetchProductPages streams the products updated after since, one products.json page at a
+// time, so callers can write each page before the next one is fetched. fn also receives the
+// URL of the next page, empty on the last one, which can be passed back as cursor to resume
+// an interrupted fetch. An error from fn stops the fetch and is returned as is.
+// It reports whether the fetch reached the end of the catalog, a short or empty page or
+// the last Link cursor, rather than giving up on a store that kept serving the same page.
+func (c *Client) FetchProductPages(ctx context.Context, domain string, since time.Time, cursor string, fn func(products []models.ShopifyProduct, next string) error) (bool, error) {
+	seen := make(map[int64]bool)
 	limit := 250 // Shopify's max limit
 
-	for {
-		// Wait for rate limiter
-		if err := c.rateLimiter.Wait(ctx); err != nil {
-			return nil, fmt.Errorf("rate limiter error: %w", err)
-		}
+	filter := ""
+	if !since.IsZero() {
+		filter = "&updated_at_min=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
 
-		url := fmt.Sprintf("https://%s/products.json?limit=%d&page=%d", domain, limit, page)
+	// Start at since_id=0 so that every page, the first included, comes in ID order;
+	// otherwise the first page follows the store's default order and since_id would skip
+	// the lower IDs it left out
+	pageURL := fmt.Sprintf("https://%s/products.json?limit=%d&since_id=0%s", domain, limit, filter)
+	if strings.HasPrefix(cursor, fmt.Sprintf("https://%s/products.json?", domain)) {
+		pageURL = cursor
+	}
 
-		products, err := c.fetchProductsPage(ctx, url)
+	for page := 1; pageURL != ""; page++ {
+		products, nextURL, err := c.fetchProductsPage(ctx, pageURL)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch page %d: %w", page, err)
+			return false, fmt.Errorf("failed to fetch page %d: %w", page, err)
 		}
 
 		if len(products) == 0 {
 			break
 		}
 
-		allProducts = append(allProducts, products...)
+		// Stores that ignore the cursor keep serving the same page,
+		// so stop once a page contains no product we haven't seen
+		fresh := 0
+		var lastID int64
+		var batch []models.ShopifyProduct
+		for _, p := range products {
+			if p.ID > lastID {
+				lastID = p.ID
+			}
+			if seen[p.ID] {
+				continue
+			}
+			seen[p.ID] = true
+			fresh++
+			if !since.IsZero() && !p.UpdatedAt.After(since) {
+				continue
+			}
+			batch = append(batch, p)
+		}
 
-		// If we got fewer products than the limit, we've reached the end
-		if len(products) < limit {
-			break
+		if fresh == 0 {
+			return false, nil
+		}
+
+		// Without a Link header, a short page means we've reached the end
+		switch {
+		case nextURL != "":
+			pageURL = nextURL
+		case len(products) < limit:
+			pageURL = ""
+		default:
+			pageURL = fmt.Sprintf("https://%s/products.json?limit=%d&since_id=%d%s", domain, limit, lastID, filter)
 		}
 
-		page++
+		if err := fn(batch, pageURL); err != nil {
+			return false, err
+		}
 	}
 
-	return allProducts, nil
+	return true, nil
 }
 
-func (c *Client) fetchProductsPage(ctx context.Context, url string) ([]models.ShopifyProduct, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (c *Client) fetchProductsPage(ctx context.Context, pageURL string) ([]models.ShopifyProduct, string, error) {
+	resp, err := c.fetcher.Get(ctx, pageURL, "application/json")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	// Password-protected stores redirect every storefront URL to their password page
+	if resp.Request.URL.Path == "/password" {
+		return nil, "", ErrPasswordProtected
 	}
 
-	req.Header.Set("User-Agent", c.config.UserAgent)
-	req.Header.Set("Accept", "application/json")
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fetcher.NewStatusError(resp)
+	}
 
-	var resp *http.Response
-	var lastErr error
+	// Other platforms answer unknown paths with their HTML storefront
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, "", fmt.Errorf("products.json served HTML: %w", ErrNotShopify)
+	}
 
-	// Retry logic with exponential backoff
-	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
-		if attempt > 0 {
-			backoff := time.Duration(attempt*attempt) * time.Second
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
+	var result models.ShopifyProductsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, "", fmt.Errorf("failed to decode response: %w", err)
+	}
 
-		resp, lastErr = c.httpClient.Do(req)
-		if lastErr != nil {
+	return result.Products, nextPageURL(resp.Request.URL, resp.Header.Get("Link")), nil
+}
+
+// nextPageURL extracts the rel="next" target from a Link header,
+// resolving it against the URL of the request that returned it
+func nextPageURL(base *url.URL, header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
 			continue
 		}
 
-		// Check for rate limiting or server errors
-		if resp.StatusCode == 429 || resp.StatusCode >= 500 {
-			resp.Body.Close()
-			lastErr = fmt.Errorf("received status code %d", resp.StatusCode)
+		isNext := false
+		for _, param := range parts[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if param == `rel="next"` || param == "rel=next" {
+				isNext = true
+				break
+			}
+		}
+		if !isNext {
 			continue
 		}
 
-		break
-	}
-
-	if lastErr != nil {
-		return nil, fmt.Errorf("all retries failed: %w", lastErr)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
-	var result models.ShopifyProductsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+		ref, err := url.Parse(target)
+		if err != nil {
+			return ""
+		}
+		return base.ResolveReference(ref).String()
 	}
 
-	return result.Products, nil
+	return ""
 }
@@ -0,0 +1,209 @@
+package shopify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/httpfixture"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestClient creates a client whose requests are answered by rt, without the polite delays
+func newTestClient(rt http.RoundTripper) *Client {
+	config := fetcher.DefaultConfig()
+	config.RequestDelay = time.Millisecond
+	config.Transport = rt
+	return NewClient(fetcher.New(config))
+}
+
+// fetchAll reads every page of the store, checking the IDs come in once and in order
+func fetchAll(t *testing.T, c *Client) (int, bool) {
+	t.Helper()
+
+	var lastID int64
+	count := 0
+	reachedEnd, err := c.FetchProductPages(context.Background(), "shop.example", time.Time{}, "", func(products []models.ShopifyProduct, next string) error {
+		for _, p := range products {
+			if p.ID <= lastID {
+				t.Fatalf("product %d came after %d", p.ID, lastID)
+			}
+			lastID = p.ID
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FetchProductPages: %v", err)
+	}
+	return count, reachedEnd
+}
+
+func TestFetchProductPagesSinceID(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(600, epoch))
+
+	count, reachedEnd := fetchAll(t, newTestClient(store.Transport()))
+	if count != 600 || !reachedEnd {
+		t.Fatalf("got %d products, reached end %v; want 600, true", count, reachedEnd)
+	}
+	if n := store.Requests("/products.json"); n != 3 {
+		t.Fatalf("made %d requests, want 3", n)
+	}
+}
+
+func TestFetchProductPagesLink(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(600, epoch))
+	store.LinkPaging = true
+	store.PageSize = 100
+
+	count, reachedEnd := fetchAll(t, newTestClient(store.Transport()))
+	if count != 600 || !reachedEnd {
+		t.Fatalf("got %d products, reached end %v; want 600, true", count, reachedEnd)
+	}
+	if n := store.Requests("/products.json"); n != 6 {
+		t.Fatalf("made %d requests, want 6", n)
+	}
+}
+
+func TestFetchProductPagesIgnoredCursor(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(600, epoch))
+	ignoring := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		q.Del("since_id")
+		r.URL.RawQuery = q.Encode()
+		store.ServeHTTP(w, r)
+	})
+
+	count, reachedEnd := fetchAll(t, newTestClient(httpfixture.HandlerTransport{Handler: ignoring}))
+	if count != 250 || reachedEnd {
+		t.Fatalf("got %d products, reached end %v; want 250, false", count, reachedEnd)
+	}
+}
+
+func TestFetchProductPagesRetryAfter(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(10, epoch))
+	store.Faults = map[string][]int{"/products.json": {http.StatusTooManyRequests}}
+
+	start := time.Now()
+	count, reachedEnd := fetchAll(t, newTestClient(store.Transport()))
+	if count != 10 || !reachedEnd {
+		t.Fatalf("got %d products, reached end %v; want 10, true", count, reachedEnd)
+	}
+	if n := store.Requests("/products.json"); n != 2 {
+		t.Fatalf("made %d requests, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("retried after %v, before Retry-After", elapsed)
+	}
+}
+
+func TestFetchProductPagesPassword(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(10, epoch))
+	store.Password = true
+
+	_, err := newTestClient(store.Transport()).FetchProducts(context.Background(), "shop.example")
+	if !errors.Is(err, ErrPasswordProtected) {
+		t.Fatalf("got %v, want ErrPasswordProtected", err)
+	}
+}
+
+func TestFetchProductPagesNotShopify(t *testing.T) {
+	storefront := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body>Welcome</body></html>"))
+	})
+
+	_, err := newTestClient(httpfixture.HandlerTransport{Handler: storefront}).FetchProducts(context.Background(), "shop.example")
+	if !errors.Is(err, ErrNotShopify) {
+		t.Fatalf("got %v, want ErrNotShopify", err)
+	}
+}
+
+func TestDetectStore(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(10, epoch))
+	store.Currency = "EUR"
+
+	// A request left holding the host slot would block until the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	info, err := newTestClient(store.Transport()).DetectStore(ctx, "shop.example")
+	if err != nil {
+		t.Fatalf("DetectStore: %v", err)
+	}
+	if info.Domain != "shop.example" || info.Name != "Shop" || info.Currency != "EUR" {
+		t.Fatalf("got domain %q, name %q, currency %q; want shop.example, Shop, EUR",
+			info.Domain, info.Name, info.Currency)
+	}
+	if n := store.Requests("/meta.json"); n != 1 {
+		t.Fatalf("made %d /meta.json requests, want 1", n)
+	}
+}
+
+func TestDetectStoreNotShopify(t *testing.T) {
+	site := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head><title>Shop</title></head><body>Welcome</body></html>"))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := newTestClient(httpfixture.HandlerTransport{Handler: site}).DetectStore(ctx, "shop.example")
+	if !errors.Is(err, ErrNotShopify) {
+		t.Fatalf("got %v, want ErrNotShopify", err)
+	}
+}
+
+func TestFetchProductsByHandle(t *testing.T) {
+	catalog := httpfixture.Catalog(3, epoch)
+	store := httpfixture.NewStore("Shop", catalog)
+	store.Faults = map[string][]int{"/products/product-2.js": {http.StatusNotFound}}
+
+	handles := []string{"product-1", "product-2", "product-3", "missing"}
+	products, failed, err := newTestClient(store.Transport()).FetchProductsByHandle(context.Background(), "shop.example", "USD", handles)
+	if err != nil {
+		t.Fatalf("FetchProductsByHandle: %v", err)
+	}
+	if len(failed) != 1 || failed[0] != "missing" {
+		t.Fatalf("got failed %v, want [missing]", failed)
+	}
+	if len(products) != 3 {
+		t.Fatalf("got %d products, want 3", len(products))
+	}
+
+	for i, p := range products {
+		want := catalog[i]
+		v, wv := p.Variants[0], want.Variants[0]
+		if len(p.Variants) != 1 || v.ID != wv.ID || v.ProductID != p.ID || v.Price != wv.Price || v.SKU != wv.SKU || !v.Available {
+			t.Errorf("got variants %+v of %s, want %+v", p.Variants, p.Handle, want.Variants)
+		}
+		if p.Title != want.Title || p.Handle != want.Handle || p.BodyHTML != want.BodyHTML || p.Vendor != want.Vendor {
+			t.Errorf("got %q %q %q %q, want %q %q %q %q", p.Title, p.Handle, p.BodyHTML, p.Vendor,
+				want.Title, want.Handle, want.BodyHTML, want.Vendor)
+		}
+		if len(p.Images) != 1 || p.Images[0].Src != want.Images[0].Src {
+			t.Errorf("got images %+v of %s, want %+v", p.Images, p.Handle, want.Images)
+		}
+
+		// Only the page has to be used for product-2, whose JSON-LD has no product ID
+		if p.Handle == "product-2" {
+			if p.ID != models.SyntheticID("handle:product-2") || !p.HandleKeyed {
+				t.Errorf("got product-2 as %d keyed by handle %v, want a synthetic ID keyed by handle", p.ID, p.HandleKeyed)
+			}
+			continue
+		}
+		if p.ID != want.ID || p.HandleKeyed || p.ProductType != want.ProductType || len(p.Tags.([]string)) != 2 {
+			t.Errorf("got product %d %q of type %q tagged %v keyed by handle %v, want %d %q tagged %v",
+				p.ID, p.Handle, p.ProductType, p.Tags, p.HandleKeyed, want.ID, want.ProductType, want.Tags)
+		}
+	}
+	if n := store.Requests("/products/product-1"); n != 0 {
+		t.Errorf("fetched the page of product-1 %d times though its .js worked", n)
+	}
+}
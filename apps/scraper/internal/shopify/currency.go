@@ -0,0 +1,83 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"indie-marketplace/scraper/internal/fetcher"
+)
+
+// storefrontCurrency matches the Shopify.currency assignment every theme renders
+var storefrontCurrency = regexp.MustCompile(`Shopify\.currency\s*=\s*\{\s*"active"\s*:\s*"([A-Z]{3})"`)
+
+// currencyCode matches an ISO 4217 code
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// minorUnits lists the ISO 4217 currencies whose minor unit isn't the usual 2 digits
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorUnit returns the number of decimals of a currency, 2 when it isn't known
+func MinorUnit(currency string) int {
+	if n, ok := minorUnits[currency]; ok {
+		return n
+	}
+	return 2
+}
+
+// FetchCurrency discovers the currency a store sells in. It tries /meta.json first,
+// then /cart.js, and finally the Shopify.currency object in the storefront HTML.
+func (c *Client) FetchCurrency(ctx context.Context, domain string) (string, error) {
+	for _, path := range []string{"/meta.json", "/cart.js"} {
+		currency, err := c.fetchCurrencyJSON(ctx, fmt.Sprintf("https://%s%s", domain, path))
+		if err == nil {
+			return currency, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+
+	html, err := c.fetcher.GetBody(ctx, fmt.Sprintf("https://%s/", domain), "text/html")
+	if err != nil {
+		return "", err
+	}
+
+	if m := storefrontCurrency.FindStringSubmatch(html); m != nil {
+		return m[1], nil
+	}
+
+	return "", fmt.Errorf("could not detect currency for %s", domain)
+}
+
+// fetchCurrencyJSON reads the top-level "currency" field of a JSON endpoint
+func (c *Client) fetchCurrencyJSON(ctx context.Context, rawURL string) (string, error) {
+	resp, err := c.fetcher.Get(ctx, rawURL, "application/json")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fetcher.NewStatusError(resp)
+	}
+
+	var result struct {
+		Currency string `json:"currency"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if !currencyCode.MatchString(result.Currency) {
+		return "", fmt.Errorf("invalid currency %q", result.Currency)
+	}
+
+	return result.Currency, nil
+}
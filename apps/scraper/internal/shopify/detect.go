@@ -0,0 +1,132 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/parser"
+)
+
+// maxHomePageSize caps how much of a home page DetectStore reads
+const maxHomePageSize = 5 << 20
+
+// StoreInfo is what DetectStore learns about a store from its website
+type StoreInfo struct {
+	Domain          string // Host the storefront is served from once redirects are followed
+	MyshopifyDomain string // Permanent xxx.myshopify.com domain, empty when the store doesn't reveal it
+	WebsiteURL      string
+	Name            string
+	Description     string
+	LogoURL         string
+	Country         string
+	Currency        string
+}
+
+// storeMeta is the subset of /meta.json used to describe a store
+type storeMeta struct {
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	Country         string `json:"country"`
+	Currency        string `json:"currency"`
+	MyshopifyDomain string `json:"myshopify_domain"`
+}
+
+// DetectStore follows a website's redirects and checks it is a Shopify store, from the
+// X-Shopify-Stage header, cdn.shopify.com assets or, failing both, a working /products.json.
+// Brand details come from the home page's meta tags, with /meta.json filling the gaps.
+func (c *Client) DetectStore(ctx context.Context, websiteURL string) (*StoreInfo, error) {
+	if !strings.Contains(websiteURL, "://") {
+		websiteURL = "https://" + websiteURL
+	}
+
+	resp, err := c.fetcher.Get(ctx, websiteURL, "text/html")
+	if err != nil {
+		return nil, err
+	}
+
+	// Closing the body frees the host slot the requests below wait for
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fetcher.NewStatusError(resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHomePageSize))
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	final := resp.Request.URL
+	meta, err := parser.ParseStorefrontMeta(string(body), final)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &StoreInfo{
+		Domain:          final.Hostname(),
+		MyshopifyDomain: meta.MyshopifyDomain,
+		WebsiteURL:      fmt.Sprintf("%s://%s", final.Scheme, final.Host),
+		Name:            meta.SiteName,
+		Description:     meta.Description,
+		LogoURL:         meta.LogoURL,
+	}
+
+	shopify := resp.Header.Get("X-Shopify-Stage") != "" || meta.ShopifyAssets
+	if !shopify {
+		_, _, err := c.fetchProductsPage(ctx, fmt.Sprintf("https://%s/products.json?limit=1", info.Domain))
+		shopify = err == nil
+	}
+	if !shopify {
+		return nil, fmt.Errorf("%s: %w", info.Domain, ErrNotShopify)
+	}
+
+	if sm, err := c.fetchStoreMeta(ctx, info.Domain); err == nil {
+		if info.Name == "" {
+			info.Name = sm.Name
+		}
+		if info.Description == "" {
+			info.Description = sm.Description
+		}
+		if info.MyshopifyDomain == "" {
+			info.MyshopifyDomain = sm.MyshopifyDomain
+		}
+		info.Country = sm.Country
+		info.Currency = sm.Currency
+	} else if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	if info.Name == "" {
+		info.Name = meta.Title
+	}
+	if info.Name == "" {
+		return nil, fmt.Errorf("could not find the name of %s", info.Domain)
+	}
+
+	return info, nil
+}
+
+// fetchStoreMeta reads /meta.json
+func (c *Client) fetchStoreMeta(ctx context.Context, domain string) (*storeMeta, error) {
+	resp, err := c.fetcher.Get(ctx, fmt.Sprintf("https://%s/meta.json", domain), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fetcher.NewStatusError(resp)
+	}
+
+	var sm storeMeta
+	if err := json.NewDecoder(resp.Body).Decode(&sm); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &sm, nil
+}
@@ -0,0 +1,159 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// productJS is /products/{handle}.js. Unlike products.json, prices there are integers in
+// the currency's minor unit and tags and images are plain lists.
+type productJS struct {
+	ID          int64       `json:"id"`
+	Title       string      `json:"title"`
+	Handle      string      `json:"handle"`
+	Description string      `json:"description"`
+	Vendor      string      `json:"vendor"`
+	Type        string      `json:"type"`
+	Tags        []string    `json:"tags"`
+	Images      []string    `json:"images"`
+	PublishedAt time.Time   `json:"published_at"`
+	CreatedAt   time.Time   `json:"created_at"`
+	Variants    []variantJS `json:"variants"`
+}
+
+// variantJS is a variant of /products/{handle}.js
+type variantJS struct {
+	ID                int64        `json:"id"`
+	Title             string       `json:"title"`
+	SKU               string       `json:"sku"`
+	Option1           string       `json:"option1"`
+	Option2           string       `json:"option2"`
+	Option3           string       `json:"option3"`
+	Available         bool         `json:"available"`
+	Price             json.Number  `json:"price"`
+	CompareAtPrice    *json.Number `json:"compare_at_price"`
+	InventoryQuantity *int         `json:"inventory_quantity"`
+}
+
+// fetchProductJS reads /products/{handle}.js
+func (c *Client) fetchProductJS(ctx context.Context, domain, handle string) (*productJS, error) {
+	resp, err := c.fetcher.Get(ctx, fmt.Sprintf("https://%s/products/%s.js", domain, url.PathEscape(handle)), "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fetcher.NewStatusError(resp)
+	}
+
+	var js productJS
+	if err := json.NewDecoder(resp.Body).Decode(&js); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &js, nil
+}
+
+// product converts a .js product to the products.json shape, prices in decimal
+func (js *productJS) product(minorUnit int) models.ShopifyProduct {
+	sp := models.ShopifyProduct{
+		ID:          js.ID,
+		Title:       js.Title,
+		Handle:      js.Handle,
+		BodyHTML:    js.Description,
+		Vendor:      js.Vendor,
+		ProductType: js.Type,
+		Tags:        js.Tags,
+		PublishedAt: js.PublishedAt,
+		CreatedAt:   js.CreatedAt,
+	}
+
+	for _, jv := range js.Variants {
+		v := models.ShopifyVariant{
+			ID:        jv.ID,
+			ProductID: js.ID,
+			Title:     jv.Title,
+			SKU:       jv.SKU,
+			Option1:   jv.Option1,
+			Option2:   jv.Option2,
+			Option3:   jv.Option3,
+			Available: jv.Available,
+		}
+		if amount, err := jv.Price.Int64(); err == nil {
+			v.Price = formatMinor(amount, minorUnit)
+		}
+		v.CompareAtPrice = compareAtPrice(jv.CompareAtPrice, minorUnit)
+		if jv.InventoryQuantity != nil {
+			v.InventoryQuantity = *jv.InventoryQuantity
+		}
+		sp.Variants = append(sp.Variants, v)
+	}
+
+	for i, src := range js.Images {
+		if strings.HasPrefix(src, "//") {
+			src = "https:" + src
+		}
+		sp.Images = append(sp.Images, models.ShopifyImage{ProductID: js.ID, Src: src, Position: i + 1})
+	}
+
+	return sp
+}
+
+// EnrichProduct fills variant availability, compare-at price and, where the store still
+// exposes it, inventory quantity from /products/{handle}.js. products.json omits or
+// zeroes these on most stores. Variants missing from the .js response are left untouched.
+// currency gives the minor unit the .js prices are expressed in.
+func (c *Client) EnrichProduct(ctx context.Context, domain, currency string, sp *models.ShopifyProduct) error {
+	js, err := c.fetchProductJS(ctx, domain, sp.Handle)
+	if err != nil {
+		return err
+	}
+
+	minorUnit := MinorUnit(currency)
+	for _, jv := range js.Variants {
+		for i := range sp.Variants {
+			v := &sp.Variants[i]
+			if v.ID != jv.ID {
+				continue
+			}
+
+			v.Available = jv.Available
+			if jv.InventoryQuantity != nil {
+				v.InventoryQuantity = *jv.InventoryQuantity
+			}
+			v.CompareAtPrice = compareAtPrice(jv.CompareAtPrice, minorUnit)
+			break
+		}
+	}
+
+	return nil
+}
+
+// compareAtPrice converts a .js compare-at price, nil when there is none
+func compareAtPrice(amount *json.Number, minorUnit int) *string {
+	if amount == nil {
+		return nil
+	}
+	n, err := amount.Int64()
+	if err != nil || n <= 0 {
+		return nil
+	}
+	price := formatMinor(n, minorUnit)
+	return &price
+}
+
+// formatMinor formats an amount in minor units as a decimal price, such as 1250 as "12.50"
+func formatMinor(amount int64, minorUnit int) string {
+	return strconv.FormatFloat(float64(amount)/math.Pow10(minorUnit), 'f', minorUnit, 64)
+}
@@ -0,0 +1,32 @@
+package shopify
+
+import (
+	"errors"
+	"net/http"
+
+	"indie-marketplace/scraper/internal/fetcher"
+)
+
+// ErrNotShopify is returned for websites that aren't, or no longer are, Shopify stores
+var ErrNotShopify = errors.New("not a Shopify store")
+
+// ErrPasswordProtected is returned when a store hides behind its password page
+var ErrPasswordProtected = errors.New("store is password protected")
+
+// IsBlocked reports whether err means the store refuses to serve products.json
+// (401, 403, 404 or a robots.txt rule) while its storefront may still be reachable
+func IsBlocked(err error) bool {
+	if fetcher.IsDisallowed(err) {
+		return true
+	}
+
+	var se *fetcher.StatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
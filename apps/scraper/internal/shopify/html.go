@@ -0,0 +1,138 @@
+package shopify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"indie-marketplace/scraper/internal/parser"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// maxCollectionPages caps how deep the HTML fallback walks /collections/all
+const maxCollectionPages = 100
+
+// CollectionHandles walks /collections/all until a page brings no new handle
+func (c *Client) CollectionHandles(ctx context.Context, domain string) ([]string, error) {
+	p := parser.NewHTMLParser()
+	seen := make(map[string]bool)
+	var handles []string
+
+	for page := 1; page <= maxCollectionPages; page++ {
+		html, err := c.fetcher.GetBody(ctx, fmt.Sprintf("https://%s/collections/all?page=%d", domain, page), "text/html")
+		if err != nil {
+			if page == 1 {
+				return nil, fmt.Errorf("failed to fetch collection page: %w", err)
+			}
+			break
+		}
+
+		found, err := p.ParseCollectionPage(html)
+		if err != nil {
+			return nil, err
+		}
+
+		fresh := 0
+		for _, h := range found {
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+			handles = append(handles, h)
+			fresh++
+		}
+
+		if fresh == 0 {
+			break
+		}
+	}
+
+	return handles, nil
+}
+
+// FetchProductsByHandle fetches each handle's product from /products/{handle}.js, which has
+// the real product and variant IDs, falling back to parsing its product page. Products that
+// fail both ways are skipped and their handles returned as failed; an error is only returned
+// when none could be read. currency gives the minor unit of the .js prices.
+func (c *Client) FetchProductsByHandle(ctx context.Context, domain, currency string, handles []string) ([]models.ShopifyProduct, []string, error) {
+	minorUnit := MinorUnit(currency)
+	var products []models.ShopifyProduct
+	var failed []string
+	var firstErr error
+
+	for _, handle := range handles {
+		sp, err := c.productByHandle(ctx, domain, minorUnit, handle)
+		if err == nil {
+			products = append(products, *sp)
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("failed to fetch product %s: %w", handle, err)
+		}
+		failed = append(failed, handle)
+	}
+
+	if len(products) == 0 && firstErr != nil {
+		return nil, nil, firstErr
+	}
+
+	return products, failed, nil
+}
+
+// productByHandle reads a product from its .js endpoint, else from its product page.
+// Pages rarely expose the IDs, JSON-LD never does, so those products are keyed by handle.
+func (c *Client) productByHandle(ctx context.Context, domain string, minorUnit int, handle string) (*models.ShopifyProduct, error) {
+	js, err := c.fetchProductJS(ctx, domain, handle)
+	if err == nil && js.ID != 0 {
+		sp := js.product(minorUnit)
+		if sp.Handle == "" {
+			sp.Handle = handle
+		}
+		return &sp, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	html, err := c.fetcher.GetBody(ctx, fmt.Sprintf("https://%s/products/%s", domain, url.PathEscape(handle)), "text/html")
+	if err != nil {
+		return nil, err
+	}
+	sp, err := parser.NewHTMLParser().ParseProductPage(html)
+	if err != nil {
+		return nil, err
+	}
+
+	if sp.Handle == "" {
+		sp.Handle = handle
+	}
+	if sp.ID == 0 {
+		sp.ID = models.SyntheticID("handle:" + handle)
+		sp.HandleKeyed = true
+	}
+	keyVariants(sp, handle)
+	return sp, nil
+}
+
+// keyVariants gives the variants a page didn't expose the ID of a stable synthetic one,
+// so their price history and rows are matched across syncs
+func keyVariants(sp *models.ShopifyProduct, handle string) {
+	for i := range sp.Variants {
+		v := &sp.Variants[i]
+		v.ProductID = sp.ID
+		if v.ID == 0 {
+			key := v.SKU
+			if key == "" {
+				key = fmt.Sprintf("#%d", i)
+			}
+			v.ID = models.SyntheticID("handle:" + handle + "|" + key)
+		}
+		if v.Title == "" {
+			v.Title = "Default Title"
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package shopify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"indie-marketplace/scraper/internal/parser"
+)
+
+// ProductURL is a product listed in a store's sitemap
+type ProductURL struct {
+	Handle  string
+	LastMod time.Time
+}
+
+// FetchProductSitemap lists the products of a store from /sitemap.xml and its
+// sitemap_products_*.xml children, with the lastmod date of each one
+func (c *Client) FetchProductSitemap(ctx context.Context, domain string) ([]ProductURL, error) {
+	root, err := c.fetchSitemap(ctx, fmt.Sprintf("https://%s/sitemap.xml", domain))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var products []ProductURL
+	collect := func(entries []parser.SitemapEntry) {
+		for _, e := range entries {
+			handle := parser.ProductHandle(e.Loc)
+			if handle == "" || seen[handle] {
+				continue
+			}
+			seen[handle] = true
+			products = append(products, ProductURL{Handle: handle, LastMod: e.LastMod})
+		}
+	}
+
+	// Some stores serve a flat urlset instead of an index
+	collect(root.URLs)
+
+	for _, child := range root.Sitemaps {
+		if !strings.Contains(child.Loc, "sitemap_products_") {
+			continue
+		}
+
+		sitemap, err := c.fetchSitemap(ctx, child.Loc)
+		if err != nil {
+			return nil, err
+		}
+		collect(sitemap.URLs)
+	}
+
+	return products, nil
+}
+
+// fetchSitemap downloads and parses a single sitemap document
+func (c *Client) fetchSitemap(ctx context.Context, sitemapURL string) (*parser.Sitemap, error) {
+	body, err := c.fetcher.GetBody(ctx, sitemapURL, "application/xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap: %w", err)
+	}
+
+	return parser.ParseSitemap([]byte(body))
+}
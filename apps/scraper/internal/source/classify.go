@@ -0,0 +1,50 @@
+package source
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/shopify"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// Classify names why fetching a brand's catalog failed, as one of the models.Failure* reasons
+func Classify(err error) string {
+	var dnsErr *net.DNSError
+	var se *fetcher.StatusError
+	switch {
+	case errors.Is(err, shopify.ErrPasswordProtected):
+		return models.FailurePasswordPage
+	case errors.Is(err, shopify.ErrNotShopify):
+		return models.FailureNotShopify
+	case fetcher.IsDisallowed(err):
+		return models.FailureRobots
+	case errors.As(err, &dnsErr):
+		return models.FailureDNS
+	case isTLSError(err):
+		return models.FailureTLS
+	case errors.As(err, &se) && se.BotWall:
+		return models.FailureBotWall
+	}
+	return models.FailureOther
+}
+
+// isTLSError reports whether err comes from the TLS handshake or certificate verification
+func isTLSError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr)
+}
@@ -0,0 +1,53 @@
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"indie-marketplace/scraper/internal/crawler"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// Generic reads any website that publishes schema.org Product JSON-LD by crawling it
+type Generic struct {
+	crawler  *crawler.Crawler
+	maxPages int
+}
+
+// NewGeneric creates a crawling source that fetches at most maxPages pages per brand and sync
+func NewGeneric(c *crawler.Crawler, maxPages int) *Generic {
+	return &Generic{crawler: c, maxPages: maxPages}
+}
+
+// Fetch implements Source. Pages carry no modification dates, so every sync is a full
+// crawl; the catalog only counts as complete when the crawl ran out of pages to visit.
+func (g *Generic) Fetch(ctx context.Context, brand models.Brand, from Position, emit EmitFunc) (*Result, error) {
+	site := crawler.Site{
+		Domain:   brand.ShopifyDomain,
+		MaxPages: g.maxPages,
+	}
+	if brand.CrawlStartURL != nil {
+		site.StartURL = *brand.CrawlStartURL
+	}
+	if brand.SitemapURL != nil {
+		site.SitemapURL = *brand.SitemapURL
+	}
+
+	crawled, err := g.crawler.Crawl(ctx, site, func(products []models.ShopifyProduct, currency string) error {
+		return emit(Page{Products: products, Currency: currency})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Mode:     models.AcquisitionCrawl,
+		Complete: crawled.Exhausted,
+	}, nil
+}
+
+// Currency implements Source. Sites only state their currency on product offers,
+// which Fetch already reports.
+func (g *Generic) Currency(ctx context.Context, brand models.Brand) (string, error) {
+	return "", fmt.Errorf("currency of %s is only known from crawled offers", brand.ShopifyDomain)
+}
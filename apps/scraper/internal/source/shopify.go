@@ -0,0 +1,209 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/shopify"
+	"indie-marketplace/scraper/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// storefrontBatch is how many product pages are fetched per emitted page
+// when products.json is blocked
+const storefrontBatch = 50
+
+// Shopify reads a Shopify store through products.json, falling back to the storefront
+// sitemap and product pages when the JSON endpoint is blocked
+type Shopify struct {
+	client       *shopify.Client
+	logger       *zap.SugaredLogger
+	enrichBudget int
+}
+
+// NewShopify creates a Shopify source. enrichBudget caps the /products/{handle}.js
+// requests per brand and sync, disabled when 0.
+func NewShopify(client *shopify.Client, logger *zap.SugaredLogger, enrichBudget int) *Shopify {
+	return &Shopify{
+		client:       client,
+		logger:       logger,
+		enrichBudget: enrichBudget,
+	}
+}
+
+// Fetch implements Source. Only products.json pages can be resumed from a cursor;
+// the storefront fallback starts over.
+func (s *Shopify) Fetch(ctx context.Context, brand models.Brand, from Position, emit EmitFunc) (*Result, error) {
+	budget := s.enrichBudget
+	enriched := 0
+	emitted := false
+	var currency string
+	currencyKnown := false
+
+	reachedEnd, err := s.client.FetchProductPages(ctx, brand.ShopifyDomain, from.Since, from.Cursor, func(products []models.ShopifyProduct, next string) error {
+		emitted = true
+		if budget > 0 && !currencyKnown {
+			currency = s.enrichCurrency(ctx, brand)
+			currencyKnown = true
+		}
+		enriched += s.enrichProducts(ctx, brand, currency, products, &budget)
+		return emit(Page{Products: products, Cursor: next})
+	})
+	if shopify.IsBlocked(err) && !emitted {
+		// The storefront may still be public even when the JSON endpoint is not
+		s.logger.Warnf("products.json blocked for %s (%v), falling back to the storefront", brand.Name, err)
+		result, fallbackErr := s.fetchFromStorefront(ctx, brand, from.Since, emit)
+
+		// No products.json and no storefront to fall back to: the brand moved off Shopify
+		var se *fetcher.StatusError
+		if fallbackErr != nil && ctx.Err() == nil && !fetcher.IsDisallowed(fallbackErr) &&
+			errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: products.json not found and storefront fallback failed: %v", shopify.ErrNotShopify, fallbackErr)
+		}
+		return result, fallbackErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Mode:     models.AcquisitionJSON,
+		Complete: reachedEnd && from.Since.IsZero() && from.Cursor == "",
+		Enriched: enriched,
+	}, nil
+}
+
+// Currency implements Source
+func (s *Shopify) Currency(ctx context.Context, brand models.Brand) (string, error) {
+	return s.client.FetchCurrency(ctx, brand.ShopifyDomain)
+}
+
+// fetchFromStorefront acquires products from the storefront when products.json is blocked.
+// The sitemap gives full coverage and lastmod dates, so only products changed since the
+// checkpoint are refetched; the /collections/all crawl is the last resort. The result
+// lists every handle the sitemap had, for retiring products on full passes.
+func (s *Shopify) fetchFromStorefront(ctx context.Context, brand models.Brand, since time.Time, emit EmitFunc) (*Result, error) {
+	listed, err := s.client.FetchProductSitemap(ctx, brand.ShopifyDomain)
+	if err == nil && len(listed) == 0 {
+		err = fmt.Errorf("sitemap lists no products")
+	}
+	if err != nil {
+		s.logger.Warnf("No usable sitemap for %s (%v), crawling collections", brand.Name, err)
+		handles, err := s.client.CollectionHandles(ctx, brand.ShopifyDomain)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.emitByHandle(ctx, brand, handles, nil, emit); err != nil {
+			return nil, err
+		}
+		return &Result{Mode: models.AcquisitionHTML}, nil
+	}
+
+	handles := make([]string, 0, len(listed))
+	lastMods := make(map[string]time.Time, len(listed))
+	var changed []string
+	for _, p := range listed {
+		handles = append(handles, p.Handle)
+		lastMods[p.Handle] = p.LastMod
+		if since.IsZero() || p.LastMod.IsZero() || p.LastMod.After(since) {
+			changed = append(changed, p.Handle)
+		}
+	}
+
+	if err := s.emitByHandle(ctx, brand, changed, lastMods, emit); err != nil {
+		return nil, err
+	}
+
+	return &Result{Mode: models.AcquisitionSitemap, Listed: handles}, nil
+}
+
+// emitByHandle fetches products by handle storefrontBatch at a time and emits each batch.
+// Storefront products carry no updated_at, so the sitemap date, when known, keeps the
+// checkpoint moving; products that failed are reported so that it doesn't move past them.
+func (s *Shopify) emitByHandle(ctx context.Context, brand models.Brand, handles []string, lastMods map[string]time.Time, emit EmitFunc) error {
+	if len(handles) == 0 {
+		return nil
+	}
+
+	currency := s.enrichCurrency(ctx, brand)
+	for start := 0; start < len(handles); start += storefrontBatch {
+		end := min(start+storefrontBatch, len(handles))
+
+		products, failed, err := s.client.FetchProductsByHandle(ctx, brand.ShopifyDomain, currency, handles[start:end])
+		if err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			s.logger.Warnf("Failed to fetch %d products of %s: %v", len(failed), brand.Name, failed)
+		}
+
+		for i := range products {
+			if products[i].UpdatedAt.IsZero() {
+				products[i].UpdatedAt = lastMods[products[i].Handle]
+			}
+			products[i].InventoryUnknown = true
+		}
+
+		if err := emit(Page{Products: products, Failed: len(failed)}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enrichCurrency returns the currency .js prices are in: the brand's, else the one detected
+func (s *Shopify) enrichCurrency(ctx context.Context, brand models.Brand) string {
+	if brand.Currency != nil && *brand.Currency != "" {
+		return *brand.Currency
+	}
+
+	currency, err := s.client.FetchCurrency(ctx, brand.ShopifyDomain)
+	if err != nil {
+		s.logger.Warnf("Failed to detect currency for %s, assuming 2 decimals: %v", brand.Name, err)
+	}
+	return currency
+}
+
+// enrichProducts fills real availability and inventory from the per-product .js endpoint,
+// most recently updated products first, while the brand's request budget lasts. products.json
+// has no inventory, so the products left out keep the quantities stored for them.
+// It returns the number of products enriched and spends budget accordingly.
+func (s *Shopify) enrichProducts(ctx context.Context, brand models.Brand, currency string, products []models.ShopifyProduct, budget *int) int {
+	for i := range products {
+		products[i].InventoryUnknown = true
+	}
+	if *budget <= 0 {
+		return 0
+	}
+
+	order := make([]int, len(products))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return products[order[a]].UpdatedAt.After(products[order[b]].UpdatedAt)
+	})
+
+	enriched := 0
+	for _, i := range order {
+		if *budget <= 0 || ctx.Err() != nil {
+			break
+		}
+		*budget--
+		if err := s.client.EnrichProduct(ctx, brand.ShopifyDomain, currency, &products[i]); err != nil {
+			s.logger.Warnf("Failed to enrich product %s of %s: %v", products[i].Handle, brand.Name, err)
+			continue
+		}
+		products[i].InventoryUnknown = false
+		enriched++
+	}
+
+	return enriched
+}
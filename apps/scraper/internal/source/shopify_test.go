@@ -0,0 +1,166 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/internal/httpfixture"
+	"indie-marketplace/scraper/internal/shopify"
+	"indie-marketplace/scraper/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestShopify creates a Shopify source whose requests are answered by rt
+func newTestShopify(rt http.RoundTripper) *Shopify {
+	config := fetcher.DefaultConfig()
+	config.RequestDelay = time.Millisecond
+	config.Transport = rt
+	return NewShopify(shopify.NewClient(fetcher.New(config)), zap.NewNop().Sugar(), 0)
+}
+
+// fetchStore runs a fetch against the store, returning the result and the products emitted
+func fetchStore(t *testing.T, rt http.RoundTripper, from Position) (*Result, []models.ShopifyProduct, error) {
+	t.Helper()
+
+	brand := models.Brand{Name: "Shop", ShopifyDomain: "shop.example"}
+	var products []models.ShopifyProduct
+	result, err := newTestShopify(rt).Fetch(context.Background(), brand, from, func(page Page) error {
+		if page.Failed > 0 {
+			t.Errorf("%d products failed", page.Failed)
+		}
+		products = append(products, page.Products...)
+		return nil
+	})
+	return result, products, err
+}
+
+func TestShopifyFetchJSON(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(300, epoch))
+
+	result, products, err := fetchStore(t, store.Transport(), Position{})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if result.Mode != models.AcquisitionJSON || !result.Complete || len(products) != 300 {
+		t.Fatalf("got mode %s, complete %v, %d products; want %s, true, 300",
+			result.Mode, result.Complete, len(products), models.AcquisitionJSON)
+	}
+
+	// An incremental fetch can't tell which products are gone
+	result, products, err = fetchStore(t, store.Transport(), Position{Since: epoch.Add(199 * time.Minute)})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if result.Complete || len(products) != 100 {
+		t.Fatalf("got complete %v, %d products; want false, 100", result.Complete, len(products))
+	}
+}
+
+func TestShopifyFetchSitemapFallback(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(60, epoch))
+	store.BlockJSON = true
+
+	result, products, err := fetchStore(t, store.Transport(), Position{})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if result.Mode != models.AcquisitionSitemap || len(products) != 60 || len(result.Listed) != 60 {
+		t.Fatalf("got mode %s, %d products, %d listed; want %s, 60, 60",
+			result.Mode, len(products), len(result.Listed), models.AcquisitionSitemap)
+	}
+	for _, p := range products {
+		if p.HandleKeyed || len(p.Variants) != 1 || p.Variants[0].ID != p.ID*1000 {
+			t.Fatalf("product %s was read without its real IDs: %+v", p.Handle, p)
+		}
+	}
+
+	// Only the pages the sitemap dates after the checkpoint are refetched
+	result, products, err = fetchStore(t, store.Transport(), Position{Since: epoch.Add(49 * time.Minute)})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(products) != 10 || len(result.Listed) != 60 {
+		t.Fatalf("got %d products, %d listed; want 10, 60", len(products), len(result.Listed))
+	}
+}
+
+func TestShopifyFetchHTMLFallback(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(30, epoch))
+	store.BlockJSON = true
+	store.Faults = map[string][]int{"/sitemap.xml": {http.StatusNotFound}}
+
+	result, products, err := fetchStore(t, store.Transport(), Position{})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if result.Mode != models.AcquisitionHTML || len(products) != 30 {
+		t.Fatalf("got mode %s, %d products; want %s, 30", result.Mode, len(products), models.AcquisitionHTML)
+	}
+	if store.Requests("/products.json") != 1 {
+		t.Fatalf("retried the blocked products.json %d times", store.Requests("/products.json")-1)
+	}
+}
+
+func TestShopifyFetchClassify(t *testing.T) {
+	password := httpfixture.NewStore("Shop", httpfixture.Catalog(10, epoch))
+	password.Password = true
+	gone := httpfixture.HandlerTransport{Handler: http.NotFoundHandler()}
+
+	tests := []struct {
+		name string
+		rt   http.RoundTripper
+		want string
+	}{
+		{"password", password.Transport(), models.FailurePasswordPage},
+		{"not shopify", gone, models.FailureNotShopify},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := fetchStore(t, tt.rt, Position{})
+			if err == nil {
+				t.Fatal("Fetch succeeded")
+			}
+			if got := Classify(err); got != tt.want {
+				t.Fatalf("Classify(%v) = %s, want %s", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShopifyFetchProductPages(t *testing.T) {
+	store := httpfixture.NewStore("Shop", httpfixture.Catalog(20, epoch))
+	store.BlockJSON = true
+
+	// Without the .js endpoint only the themes' JSON-LD is left, which has no product ID
+	noJS := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".js") {
+			http.NotFound(w, r)
+			return
+		}
+		store.ServeHTTP(w, r)
+	})
+
+	result, products, err := fetchStore(t, httpfixture.HandlerTransport{Handler: noJS}, Position{})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if result.Mode != models.AcquisitionSitemap || len(products) != 20 {
+		t.Fatalf("got mode %s, %d products; want %s, 20", result.Mode, len(products), models.AcquisitionSitemap)
+	}
+	for _, p := range products {
+		if !p.HandleKeyed || p.ID != models.SyntheticID("handle:"+p.Handle) {
+			t.Fatalf("product %s was not keyed by handle", p.Handle)
+		}
+		if len(p.Variants) != 1 || p.Variants[0].ID == 0 || p.Variants[0].ProductID != p.ID || p.Variants[0].Price == "" {
+			t.Fatalf("product %s has variants %+v, want its real variant ID and price", p.Handle, p.Variants)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package source
+
+import (
+	"context"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// Source acquires a brand's catalog from the platform its store runs on
+type Source interface {
+	// Fetch streams the products changed since from.Since, or the whole catalog when it is
+	// zero, to emit one page at a time. Sources that can't filter by date stream everything.
+	// An error from emit stops the fetch and is returned.
+	Fetch(ctx context.Context, brand models.Brand, from Position, emit EmitFunc) (*Result, error)
+
+	// Currency detects the currency the store sells in
+	Currency(ctx context.Context, brand models.Brand) (string, error)
+}
+
+// Position is where a fetch starts
+type Position struct {
+	Since  time.Time // Only products updated after Since, all of them when zero
+	Cursor string    // Where an interrupted fetch stopped, from Page.Cursor; empty starts over
+}
+
+// Page is a batch of products handed over as soon as it is fetched
+type Page struct {
+	Products []models.ShopifyProduct
+	Currency string // Currency of the prices, when the source learnt it while fetching
+	Cursor   string // Resumes the fetch after this page, empty when it can't be resumed
+	Failed   int    // Products that couldn't be fetched, which hold the checkpoint and cursor back
+}
+
+// EmitFunc receives the pages of a fetch
+type EmitFunc func(page Page) error
+
+// Result sums up a fetch once every page was emitted
+type Result struct {
+	Mode     string   // How the products were acquired, one of the models.Acquisition* constants
+	Complete bool     // The pages held the whole live catalog, so missing IDs can be retired
+	Listed   []string // Handles of every live product when only the changed ones were fetched
+	Enriched int      // Products whose availability was filled by an extra request
+}
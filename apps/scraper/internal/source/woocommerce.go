@@ -0,0 +1,41 @@
+package source
+
+import (
+	"context"
+
+	"indie-marketplace/scraper/internal/woocommerce"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// WooCommerce reads a WooCommerce store through its public Store API
+type WooCommerce struct {
+	client *woocommerce.Client
+}
+
+// NewWooCommerce creates a WooCommerce source
+func NewWooCommerce(client *woocommerce.Client) *WooCommerce {
+	return &WooCommerce{client: client}
+}
+
+// Fetch implements Source. The Store API exposes no modification dates, so the whole
+// catalog is fetched and the content hash skips unchanged products. Pages are numbered
+// rather than cursored, so an interrupted fetch starts over. A catalog too large to read
+// to its last page isn't complete, so nothing is retired from it.
+func (w *WooCommerce) Fetch(ctx context.Context, brand models.Brand, from Position, emit EmitFunc) (*Result, error) {
+	reachedEnd, err := w.client.FetchProductPages(ctx, brand.ShopifyDomain, func(products []models.ShopifyProduct) error {
+		return emit(Page{Products: products})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Mode:     models.AcquisitionStoreAPI,
+		Complete: reachedEnd,
+	}, nil
+}
+
+// Currency implements Source
+func (w *WooCommerce) Currency(ctx context.Context, brand models.Brand) (string, error) {
+	return w.client.FetchCurrency(ctx, brand.ShopifyDomain)
+}
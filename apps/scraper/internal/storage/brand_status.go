@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// RecordBrandFailure counts a failed sync against a brand and stores why it failed.
+// Once maxFailures syncs in a row failed the brand is deactivated; 0 never deactivates.
+// Every change of status or reason is written to brand_status_events. It returns the
+// brand's new status.
+func (db *DB) RecordBrandFailure(ctx context.Context, brandID, reason, message string, maxFailures int) (string, error) {
+	var status string
+	err := db.pool.QueryRow(ctx, `
+		WITH prev AS (
+			SELECT id, status, status_reason FROM brands WHERE id = $1 FOR UPDATE
+		), updated AS (
+			UPDATE brands b
+			SET consecutive_failures = b.consecutive_failures + 1,
+			    status = CASE WHEN $3::int > 0 AND b.consecutive_failures + 1 >= $3::int
+			                  THEN 'deactivated' ELSE 'failing' END,
+			    status_reason = $2::text,
+			    is_active = CASE WHEN $3::int > 0 AND b.consecutive_failures + 1 >= $3::int
+			                     THEN false ELSE b.is_active END,
+			    updated_at = NOW()
+			FROM prev
+			WHERE b.id = prev.id
+			RETURNING prev.status AS from_status, prev.status_reason AS from_reason, b.status AS to_status
+		), event AS (
+			INSERT INTO brand_status_events (brand_id, from_status, to_status, reason, message)
+			SELECT $1, from_status, to_status, $2::text, $4::text
+			FROM updated
+			WHERE from_status IS DISTINCT FROM to_status OR from_reason IS DISTINCT FROM $2::text
+		)
+		SELECT to_status FROM updated
+	`, brandID, reason, maxFailures, message).Scan(&status)
+	if err != nil {
+		return "", fmt.Errorf("failed to record brand failure: %w", err)
+	}
+
+	return status, nil
+}
+
+// RecordBrandSuccess marks a brand active again after a sync went through,
+// resetting its failure count and recording the recovery in brand_status_events
+func (db *DB) RecordBrandSuccess(ctx context.Context, brandID string) error {
+	_, err := db.pool.Exec(ctx, `
+		WITH prev AS (
+			SELECT id, status FROM brands WHERE id = $1 FOR UPDATE
+		), updated AS (
+			UPDATE brands b
+			SET consecutive_failures = 0, status = 'active', status_reason = NULL, updated_at = NOW()
+			FROM prev
+			WHERE b.id = prev.id AND (prev.status <> 'active' OR b.consecutive_failures > 0)
+			RETURNING prev.status AS from_status
+		)
+		INSERT INTO brand_status_events (brand_id, from_status, to_status)
+		SELECT $1, from_status, 'active' FROM updated WHERE from_status <> 'active'
+	`, brandID)
+	if err != nil {
+		return fmt.Errorf("failed to record brand success: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,434 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// bulkChunkSize is the number of changed products merged per transaction
+const bulkChunkSize = 100
+
+// ProductError reports a product that UpsertProducts could not write
+type ProductError struct {
+	ShopifyID int64
+	Title     string
+	Err       error
+}
+
+func (e ProductError) Error() string {
+	return fmt.Sprintf("product %d (%s): %v", e.ShopifyID, e.Title, e.Err)
+}
+
+// BulkResult summarizes an UpsertProducts call
+type BulkResult struct {
+	Created         int
+	Updated         int
+	Unchanged       int
+	Restored        int
+	PriceChanges    int
+	VariantsChanged int
+	ImagesChanged   int
+	Failures        []ProductError
+}
+
+// add folds the outcome of a single product into the summary
+func (r *BulkResult) add(res UpsertResult) {
+	switch {
+	case res.Unchanged:
+		r.Unchanged++
+	case res.Created:
+		r.Created++
+	default:
+		r.Updated++
+	}
+	if res.Restored {
+		r.Restored++
+	}
+	r.PriceChanges += res.PriceChanges
+	r.VariantsChanged += res.VariantsChanged
+	r.ImagesChanged += res.ImagesChanged
+}
+
+// storedProduct is the state of a product row needed to plan a bulk upsert
+type storedProduct struct {
+	hash      *string
+	removedAt *time.Time
+}
+
+// UpsertProducts writes a brand's products in bulk. Unchanged products are filtered out
+// with a single query, the rest are copied into a staging table and merged chunk by chunk,
+// with their variants, images and price history sent as one pgx.Batch per chunk.
+// When a chunk fails, its products are retried one by one so that a single bad
+// product is reported in Failures instead of aborting the whole brand.
+func (db *DB) UpsertProducts(ctx context.Context, brandID, currency string, products []models.ShopifyProduct) (BulkResult, error) {
+	var result BulkResult
+
+	products, err := db.adoptStoredIDs(ctx, brandID, products)
+	if err != nil {
+		return result, err
+	}
+
+	// Stores occasionally list a product twice; the merge can only touch a row once
+	index := make(map[int64]int, len(products))
+	var unique []models.ShopifyProduct
+	for _, p := range products {
+		if i, ok := index[p.ID]; ok {
+			unique[i] = p
+			continue
+		}
+		index[p.ID] = len(unique)
+		unique = append(unique, p)
+	}
+
+	if err := db.keepStoredInventory(ctx, brandID, unique); err != nil {
+		return result, err
+	}
+
+	ids := make([]int64, len(unique))
+	hashes := make([]string, len(unique))
+	for i, p := range unique {
+		ids[i] = p.ID
+		hashes[i] = contentHash(p, currency)
+	}
+
+	stored, err := db.loadStoredProducts(ctx, brandID, ids)
+	if err != nil {
+		return result, err
+	}
+
+	rate, err := db.latestRate(ctx, currency)
+	if err != nil {
+		return result, err
+	}
+
+	var changed []models.ShopifyProduct
+	var changedHashes []string
+	for i, p := range unique {
+		sp, ok := stored[p.ID]
+		if ok && sp.hash != nil && *sp.hash == hashes[i] && sp.removedAt == nil {
+			result.add(UpsertResult{Unchanged: true})
+			continue
+		}
+		changed = append(changed, p)
+		changedHashes = append(changedHashes, hashes[i])
+	}
+
+	for start := 0; start < len(changed); start += bulkChunkSize {
+		end := min(start+bulkChunkSize, len(changed))
+		chunk := changed[start:end]
+
+		results, err := db.upsertChunk(ctx, brandID, currency, rate, chunk, changedHashes[start:end], stored)
+		if err == nil {
+			for _, res := range results {
+				result.add(res)
+			}
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		// Isolate the product(s) that broke the chunk
+		for _, p := range chunk {
+			res, err := db.UpsertProduct(ctx, brandID, currency, p)
+			if err != nil {
+				result.Failures = append(result.Failures, ProductError{ShopifyID: p.ID, Title: p.Title, Err: err})
+				continue
+			}
+			result.add(res)
+		}
+	}
+
+	return result, nil
+}
+
+// loadStoredProducts returns the hash and retirement state of the brand's products among ids
+func (db *DB) loadStoredProducts(ctx context.Context, brandID string, ids []int64) (map[int64]storedProduct, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT shopify_id, content_hash, removed_at
+		FROM products
+		WHERE brand_id = $1 AND shopify_id = ANY($2)
+	`, brandID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[int64]storedProduct, len(ids))
+	for rows.Next() {
+		var id int64
+		var sp storedProduct
+		if err := rows.Scan(&id, &sp.hash, &sp.removedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		stored[id] = sp
+	}
+
+	return stored, rows.Err()
+}
+
+// keepStoredInventory gives the variants of products whose source couldn't read inventory
+// this time their stored quantities, so that a product enriched on some syncs and not on
+// others isn't rewritten back and forth between the two
+func (db *DB) keepStoredInventory(ctx context.Context, brandID string, products []models.ShopifyProduct) error {
+	var ids []int64
+	for _, p := range products {
+		if p.InventoryUnknown {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := db.pool.Query(ctx, `
+		SELECT p.shopify_id, v.shopify_id, COALESCE(v.inventory_quantity, 0)
+		FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		WHERE p.brand_id = $1 AND p.shopify_id = ANY($2)
+	`, brandID, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query inventory: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[[2]int64]int)
+	for rows.Next() {
+		var productID, variantID int64
+		var quantity int
+		if err := rows.Scan(&productID, &variantID, &quantity); err != nil {
+			return fmt.Errorf("failed to scan inventory: %w", err)
+		}
+		stored[[2]int64{productID, variantID}] = quantity
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to query inventory: %w", err)
+	}
+
+	for i := range products {
+		p := &products[i]
+		if !p.InventoryUnknown {
+			continue
+		}
+		p.Variants = append([]models.ShopifyVariant(nil), p.Variants...)
+		for j := range p.Variants {
+			if quantity, ok := stored[[2]int64{p.ID, p.Variants[j].ID}]; ok {
+				p.Variants[j].InventoryQuantity = quantity
+			}
+		}
+	}
+
+	return nil
+}
+
+// storedHandle is the product stored under a handle, with its variants keyed for matching
+type storedHandle struct {
+	id      int64
+	bySKU   map[string]int64
+	byTitle map[string]int64
+	only    int64 // The single variant's ID, 0 unless there is exactly one
+}
+
+// adoptStoredIDs gives products keyed by handle the IDs of the product already stored under
+// that handle, typically from products.json before the store blocked it, so the storefront
+// fallback updates it rather than adding a duplicate. Variants are matched by SKU, then title.
+// Products never stored keep their synthetic IDs. products itself is left untouched.
+func (db *DB) adoptStoredIDs(ctx context.Context, brandID string, products []models.ShopifyProduct) ([]models.ShopifyProduct, error) {
+	var handles []string
+	for _, p := range products {
+		if p.HandleKeyed {
+			handles = append(handles, p.Handle)
+		}
+	}
+	if len(handles) == 0 {
+		return products, nil
+	}
+
+	// A live product wins over a retired one of the same handle
+	rows, err := db.pool.Query(ctx, `
+		WITH stored AS (
+			SELECT DISTINCT ON (slug) id, slug, shopify_id
+			FROM products
+			WHERE brand_id = $1 AND slug = ANY($2)
+			ORDER BY slug, removed_at IS NOT NULL, updated_at DESC
+		)
+		SELECT s.slug, s.shopify_id, v.shopify_id, COALESCE(v.sku, ''), COALESCE(v.title, '')
+		FROM stored s
+		LEFT JOIN product_variants v ON v.product_id = s.id
+	`, brandID, handles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored products: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]*storedHandle)
+	variants := make(map[string]int)
+	for rows.Next() {
+		var slug, sku, title string
+		var productID int64
+		var variantID *int64
+		if err := rows.Scan(&slug, &productID, &variantID, &sku, &title); err != nil {
+			return nil, fmt.Errorf("failed to scan stored product: %w", err)
+		}
+
+		sh, ok := stored[slug]
+		if !ok {
+			sh = &storedHandle{id: productID, bySKU: make(map[string]int64), byTitle: make(map[string]int64)}
+			stored[slug] = sh
+		}
+		if variantID == nil {
+			continue
+		}
+		if sku != "" {
+			sh.bySKU[sku] = *variantID
+		}
+		if title != "" {
+			sh.byTitle[title] = *variantID
+		}
+		sh.only = *variantID
+		variants[slug]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query stored products: %w", err)
+	}
+
+	adopted := append([]models.ShopifyProduct(nil), products...)
+	for i := range adopted {
+		p := &adopted[i]
+		sh, ok := stored[p.Handle]
+		if !p.HandleKeyed || !ok {
+			continue
+		}
+
+		p.ID = sh.id
+		p.Variants = append([]models.ShopifyVariant(nil), p.Variants...)
+		used := make(map[int64]bool)
+		for j := range p.Variants {
+			v := &p.Variants[j]
+			v.ProductID = p.ID
+
+			id, ok := sh.bySKU[v.SKU]
+			if v.SKU == "" || !ok {
+				id, ok = sh.byTitle[v.Title]
+			}
+			if !ok && len(p.Variants) == 1 && variants[p.Handle] == 1 {
+				id, ok = sh.only, true
+			}
+			if ok && !used[id] {
+				v.ID = id
+				used[id] = true
+			}
+		}
+	}
+
+	return adopted, nil
+}
+
+// stagingColumns are the columns copied into the product_staging temp table
+var stagingColumns = []string{
+	"shopify_id", "title", "slug", "description", "product_type", "vendor", "tags",
+	"price_min", "price_max", "compare_at_price", "is_available", "published_at", "content_hash",
+	"price_min_eur", "price_max_eur", "canonical_url",
+}
+
+// upsertChunk merges one chunk of changed products in a single transaction
+func (db *DB) upsertChunk(ctx context.Context, brandID, currency string, rate *float64, chunk []models.ShopifyProduct, hashes []string, stored map[int64]storedProduct) ([]UpsertResult, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMP TABLE product_staging (
+			shopify_id       bigint,
+			title            text,
+			slug             text,
+			description      text,
+			product_type     text,
+			vendor           text,
+			tags             text[],
+			price_min        numeric,
+			price_max        numeric,
+			compare_at_price numeric,
+			is_available     boolean,
+			published_at     timestamptz,
+			content_hash     text,
+			price_min_eur    numeric,
+			price_max_eur    numeric,
+			canonical_url    text
+		) ON COMMIT DROP
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	rows := make([][]any, len(chunk))
+	for i, sp := range chunk {
+		sum := summarize(sp)
+		rows[i] = []any{
+			sp.ID, sp.Title, sp.Handle, sp.BodyHTML, sp.ProductType, sp.Vendor, sum.tags,
+			sum.priceMin, sum.priceMax, sum.compareAtPrice, sum.isAvailable, sp.PublishedAt, hashes[i],
+			toEUR(sum.priceMin, rate), toEUR(sum.priceMax, rate), sp.CanonicalURL,
+		}
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"product_staging"}, stagingColumns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy products: %w", err)
+	}
+
+	merged, err := tx.Query(ctx, productInsert+`
+		SELECT $1, shopify_id, title, slug, description, product_type, vendor, tags,
+		       price_min, price_max, $2, compare_at_price, is_available, published_at,
+		       content_hash, price_min_eur, price_max_eur, NULLIF(canonical_url, ''), NOW()
+		FROM product_staging
+	`+productOnConflict+`
+		RETURNING shopify_id, id, (xmax = 0) as created
+	`, brandID, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge products: %w", err)
+	}
+
+	positions := make(map[int64]int, len(chunk))
+	for i, sp := range chunk {
+		positions[sp.ID] = i
+	}
+
+	results := make([]UpsertResult, len(chunk))
+	written := make([]writtenProduct, 0, len(chunk))
+	for merged.Next() {
+		var shopifyID int64
+		var productID string
+		var created bool
+		if err := merged.Scan(&shopifyID, &productID, &created); err != nil {
+			merged.Close()
+			return nil, fmt.Errorf("failed to scan merged product: %w", err)
+		}
+
+		i := positions[shopifyID]
+		results[i].Created = created
+		results[i].Restored = stored[shopifyID].removedAt != nil
+		written = append(written, writtenProduct{id: productID, sp: &chunk[i], res: &results[i]})
+	}
+	merged.Close()
+	if err := merged.Err(); err != nil {
+		return nil, fmt.Errorf("failed to merge products: %w", err)
+	}
+
+	if err := reconcileChildren(ctx, tx, written); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return results, nil
+}
@@ -2,13 +2,14 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"time"
 
 	"indie-marketplace/scraper/pkg/models"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -32,16 +33,40 @@ func NewDB(ctx context.Context, connString string) (*DB, error) {
 	return &DB{pool: pool}, nil
 }
 
+// Ping checks the database is reachable
+func (db *DB) Ping(ctx context.Context) error {
+	return db.pool.Ping(ctx)
+}
+
 // Close closes the database connection
 func (db *DB) Close() {
 	db.pool.Close()
 }
 
+// brandColumns are the columns scanBrand reads, in order
+const brandColumns = `
+	id, name, slug, description, logo_url, website_url, shopify_domain, myshopify_domain, platform,
+	crawl_start_url, sitemap_url, country, currency, is_active, last_synced_at,
+	sync_checkpoint, last_full_sync_at, sync_cursor, sync_cursor_since, sync_cursor_full,
+	sync_schedule, sync_priority, sync_interval_seconds, sync_interval_reason, sync_interval_set_at,
+	status, status_reason, consecutive_failures, created_at, updated_at`
+
+// scanBrand reads a row selected with brandColumns
+func scanBrand(row pgx.Row) (models.Brand, error) {
+	var b models.Brand
+	err := row.Scan(
+		&b.ID, &b.Name, &b.Slug, &b.Description, &b.LogoURL, &b.WebsiteURL, &b.ShopifyDomain, &b.MyshopifyDomain, &b.Platform,
+		&b.CrawlStartURL, &b.SitemapURL, &b.Country, &b.Currency, &b.IsActive, &b.LastSyncedAt,
+		&b.SyncCheckpoint, &b.LastFullSyncAt, &b.SyncCursor, &b.SyncCursorSince, &b.SyncCursorFull,
+		&b.SyncSchedule, &b.SyncPriority, &b.SyncInterval, &b.SyncIntervalReason, &b.SyncIntervalSetAt,
+		&b.Status, &b.StatusReason, &b.Failures, &b.CreatedAt, &b.UpdatedAt,
+	)
+	return b, err
+}
+
 // GetActiveBrands returns all active brands
 func (db *DB) GetActiveBrands(ctx context.Context) ([]models.Brand, error) {
-	query := `
-		SELECT id, name, slug, description, logo_url, website_url, shopify_domain,
-		       country, is_active, last_synced_at, created_at, updated_at
+	query := `SELECT ` + brandColumns + `
 		FROM brands
 		WHERE is_active = true
 		ORDER BY name
@@ -55,11 +80,7 @@ func (db *DB) GetActiveBrands(ctx context.Context) ([]models.Brand, error) {
 
 	var brands []models.Brand
 	for rows.Next() {
-		var b models.Brand
-		err := rows.Scan(
-			&b.ID, &b.Name, &b.Slug, &b.Description, &b.LogoURL, &b.WebsiteURL,
-			&b.ShopifyDomain, &b.Country, &b.IsActive, &b.LastSyncedAt, &b.CreatedAt, &b.UpdatedAt,
-		)
+		b, err := scanBrand(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan brand: %w", err)
 		}
@@ -69,65 +90,116 @@ func (db *DB) GetActiveBrands(ctx context.Context) ([]models.Brand, error) {
 	return brands, nil
 }
 
-// UpsertProduct inserts or updates a product
-func (db *DB) UpsertProduct(ctx context.Context, brandID string, sp models.ShopifyProduct) (created bool, err error) {
-	tx, err := db.pool.Begin(ctx)
+// GetActiveBrand returns an active brand by ID, nil when it doesn't exist or was deactivated
+func (db *DB) GetActiveBrand(ctx context.Context, brandID string) (*models.Brand, error) {
+	row := db.pool.QueryRow(ctx, `SELECT `+brandColumns+`
+		FROM brands
+		WHERE id = $1 AND is_active = true
+	`, brandID)
+
+	b, err := scanBrand(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
-		return false, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to query brand: %w", err)
 	}
-	defer tx.Rollback(ctx)
 
-	// Calculate price range from variants
-	var priceMin, priceMax float64
-	var compareAtPrice *float64
+	return &b, nil
+}
 
-	for i, v := range sp.Variants {
-		price, _ := strconv.ParseFloat(v.Price, 64)
-		if i == 0 || price < priceMin {
-			priceMin = price
-		}
-		if i == 0 || price > priceMax {
-			priceMax = price
-		}
-		if v.CompareAtPrice != nil && *v.CompareAtPrice != "" {
-			cap, _ := strconv.ParseFloat(*v.CompareAtPrice, 64)
-			if compareAtPrice == nil || cap > *compareAtPrice {
-				compareAtPrice = &cap
-			}
-		}
+// GetBrandByDomain returns the brand of a store domain, active or not, nil when there is none
+func (db *DB) GetBrandByDomain(ctx context.Context, domain string) (*models.Brand, error) {
+	row := db.pool.QueryRow(ctx, `SELECT `+brandColumns+`
+		FROM brands
+		WHERE shopify_domain = $1
+	`, domain)
+
+	b, err := scanBrand(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query brand: %w", err)
 	}
 
-	// Check if any variant is available
-	isAvailable := false
-	for _, v := range sp.Variants {
-		if v.Available {
-			isAvailable = true
-			break
-		}
+	return &b, nil
+}
+
+// uniqueViolation is the Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+// ErrBrandExists is returned by CreateBrand when the slug or store domain is taken
+var ErrBrandExists = errors.New("brand already exists")
+
+// CreateBrand inserts a new, inactive brand and returns its ID.
+// Brands are only activated once a trial sync went through.
+func (db *DB) CreateBrand(ctx context.Context, b models.Brand) (string, error) {
+	var id string
+	err := db.pool.QueryRow(ctx, `
+		INSERT INTO brands (name, slug, description, logo_url, website_url, shopify_domain, myshopify_domain,
+		                    platform, country, currency, is_active, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, false, 'pending')
+		RETURNING id
+	`, b.Name, b.Slug, b.Description, b.LogoURL, b.WebsiteURL, b.ShopifyDomain, b.MyshopifyDomain,
+		b.Platform, b.Country, b.Currency).Scan(&id)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return "", fmt.Errorf("%s: %w", b.ShopifyDomain, ErrBrandExists)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to insert brand: %w", err)
 	}
 
-	// Parse tags - handle both string and array formats from Shopify
-	var tags []string
-	switch t := sp.Tags.(type) {
-	case string:
-		if t != "" {
-			tags = strings.Split(t, ", ")
-		}
-	case []interface{}:
-		for _, v := range t {
-			if s, ok := v.(string); ok {
-				tags = append(tags, s)
-			}
-		}
+	return id, nil
+}
+
+// ActivateBrand makes a brand part of the scheduled syncs with status active,
+// recording the change in brand_status_events
+func (db *DB) ActivateBrand(ctx context.Context, brandID string) error {
+	_, err := db.pool.Exec(ctx, `
+		WITH prev AS (
+			SELECT id, status FROM brands WHERE id = $1 FOR UPDATE
+		), updated AS (
+			UPDATE brands b
+			SET is_active = true, status = 'active', status_reason = NULL, consecutive_failures = 0,
+			    updated_at = NOW()
+			FROM prev
+			WHERE b.id = prev.id
+			RETURNING prev.status AS from_status
+		)
+		INSERT INTO brand_status_events (brand_id, from_status, to_status)
+		SELECT $1, from_status, 'active' FROM updated WHERE from_status <> 'active'
+	`, brandID)
+	if err != nil {
+		return fmt.Errorf("failed to activate brand: %w", err)
 	}
 
-	// Upsert product
-	var productID string
-	var wasCreated bool
-	query := `
+	return nil
+}
+
+// UpsertResult describes what UpsertProduct did to a product
+type UpsertResult struct {
+	Created         bool
+	Unchanged       bool // The content hash matched, so nothing was written
+	Restored        bool // The product had been retired and is listed by the store again
+	PriceChanges    int  // Variants whose price or compare-at price changed
+	VariantsChanged int  // Variant rows inserted, updated or deleted
+	ImagesChanged   int  // Image rows inserted, updated or deleted
+}
+
+// productInsert starts the statement writing product rows, shared by UpsertProduct and
+// UpsertProducts; the values follow, in this column order
+const productInsert = `
 		INSERT INTO products (brand_id, shopify_id, title, slug, description, product_type, vendor, tags,
-		                      price_min, price_max, currency, compare_at_price, is_available, published_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 'EUR', $11, $12, $13, NOW())
+		                      price_min, price_max, currency, compare_at_price, is_available, published_at,
+		                      content_hash, price_min_eur, price_max_eur, canonical_url, updated_at)
+`
+
+// productOnConflict updates the product already stored under the same shopify_id,
+// bringing it back if it was retired
+const productOnConflict = `
 		ON CONFLICT (brand_id, shopify_id) DO UPDATE SET
 			title = EXCLUDED.title,
 			slug = EXCLUDED.slug,
@@ -137,71 +209,160 @@ func (db *DB) UpsertProduct(ctx context.Context, brandID string, sp models.Shopi
 			tags = EXCLUDED.tags,
 			price_min = EXCLUDED.price_min,
 			price_max = EXCLUDED.price_max,
+			currency = EXCLUDED.currency,
 			compare_at_price = EXCLUDED.compare_at_price,
 			is_available = EXCLUDED.is_available,
 			published_at = EXCLUDED.published_at,
+			content_hash = EXCLUDED.content_hash,
+			price_min_eur = EXCLUDED.price_min_eur,
+			price_max_eur = EXCLUDED.price_max_eur,
+			canonical_url = EXCLUDED.canonical_url,
+			removed_at = NULL,
 			updated_at = NOW()
+`
+
+// UpsertProduct inserts or updates a product priced in the given currency, restoring it if it
+// had been retired. Products whose content hash matches the stored one are skipped without a transaction.
+func (db *DB) UpsertProduct(ctx context.Context, brandID, currency string, sp models.ShopifyProduct) (UpsertResult, error) {
+	var res UpsertResult
+	hash := contentHash(sp, currency)
+
+	var storedHash *string
+	var storedRemovedAt *time.Time
+	err := db.pool.QueryRow(ctx,
+		"SELECT content_hash, removed_at FROM products WHERE brand_id = $1 AND shopify_id = $2",
+		brandID, sp.ID).Scan(&storedHash, &storedRemovedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return res, fmt.Errorf("failed to load product: %w", err)
+	}
+	if storedHash != nil && *storedHash == hash && storedRemovedAt == nil {
+		res.Unchanged = true
+		return res, nil
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return res, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the existing row, if any, to know whether this brings back a retired product
+	var removedAt *time.Time
+	err = tx.QueryRow(ctx,
+		"SELECT removed_at FROM products WHERE brand_id = $1 AND shopify_id = $2 FOR UPDATE",
+		brandID, sp.ID).Scan(&removedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return res, fmt.Errorf("failed to load product: %w", err)
+	}
+	res.Restored = removedAt != nil
+
+	sum := summarize(sp)
+
+	rate, err := db.latestRate(ctx, currency)
+	if err != nil {
+		return res, err
+	}
+
+	// Upsert product
+	var productID string
+	query := productInsert + `
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NULLIF($18, ''), NOW())
+	` + productOnConflict + `
 		RETURNING id, (xmax = 0) as created
 	`
 
 	err = tx.QueryRow(ctx, query,
 		brandID, sp.ID, sp.Title, sp.Handle, sp.BodyHTML, sp.ProductType, sp.Vendor,
-		tags, priceMin, priceMax, compareAtPrice, isAvailable, sp.PublishedAt,
-	).Scan(&productID, &wasCreated)
+		sum.tags, sum.priceMin, sum.priceMax, currency, sum.compareAtPrice, sum.isAvailable, sp.PublishedAt, hash,
+		toEUR(sum.priceMin, rate), toEUR(sum.priceMax, rate), sp.CanonicalURL,
+	).Scan(&productID, &res.Created)
 
 	if err != nil {
-		return false, fmt.Errorf("failed to upsert product: %w", err)
+		return res, fmt.Errorf("failed to upsert product: %w", err)
 	}
 
-	// Delete existing variants and images
-	_, err = tx.Exec(ctx, "DELETE FROM product_variants WHERE product_id = $1", productID)
+	err = reconcileChildren(ctx, tx, []writtenProduct{{id: productID, sp: &sp, res: &res}})
 	if err != nil {
-		return false, fmt.Errorf("failed to delete variants: %w", err)
+		return res, err
 	}
 
-	_, err = tx.Exec(ctx, "DELETE FROM product_images WHERE product_id = $1", productID)
-	if err != nil {
-		return false, fmt.Errorf("failed to delete images: %w", err)
+	if err := tx.Commit(ctx); err != nil {
+		return res, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Insert variants
-	for _, v := range sp.Variants {
-		price, _ := strconv.ParseFloat(v.Price, 64)
-		var cap *float64
-		if v.CompareAtPrice != nil && *v.CompareAtPrice != "" {
-			c, _ := strconv.ParseFloat(*v.CompareAtPrice, 64)
-			cap = &c
-		}
+	return res, nil
+}
 
-		_, err = tx.Exec(ctx, `
-			INSERT INTO product_variants (product_id, shopify_id, title, sku, price, compare_at_price,
-			                              inventory_quantity, option1, option2, option3, is_available)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		`, productID, v.ID, v.Title, v.SKU, price, cap, v.InventoryQuantity,
-			v.Option1, v.Option2, v.Option3, v.Available)
+// productSummary holds the product-level values derived from a product's variants and tags
+type productSummary struct {
+	priceMin       float64
+	priceMax       float64
+	compareAtPrice *float64
+	isAvailable    bool
+	tags           []string
+}
 
-		if err != nil {
-			return false, fmt.Errorf("failed to insert variant: %w", err)
+// summarize computes the price range, availability and tags stored on the product row
+func summarize(sp models.ShopifyProduct) productSummary {
+	sum := productSummary{tags: parseTags(sp.Tags)}
+
+	for i, v := range sp.Variants {
+		price, cap := variantPrices(v)
+		if i == 0 || price < sum.priceMin {
+			sum.priceMin = price
+		}
+		if i == 0 || price > sum.priceMax {
+			sum.priceMax = price
+		}
+		if cap != nil && (sum.compareAtPrice == nil || *cap > *sum.compareAtPrice) {
+			sum.compareAtPrice = cap
+		}
+		if v.Available {
+			sum.isAvailable = true
 		}
 	}
 
-	// Insert images
-	for _, img := range sp.Images {
-		_, err = tx.Exec(ctx, `
-			INSERT INTO product_images (product_id, shopify_id, src, alt_text, width, height, position)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-		`, productID, img.ID, img.Src, img.Alt, img.Width, img.Height, img.Position)
+	return sum
+}
 
-		if err != nil {
-			return false, fmt.Errorf("failed to insert image: %w", err)
-		}
+// RetireMissingProducts marks the brand's live products whose shopify_id is not in seen as removed.
+// Only call it after a complete fetch of the store, otherwise live products get retired.
+func (db *DB) RetireMissingProducts(ctx context.Context, brandID string, seen []int64) (int, error) {
+	tag, err := db.pool.Exec(ctx, `
+		UPDATE products
+		SET removed_at = NOW(), is_available = false, updated_at = NOW()
+		WHERE brand_id = $1 AND removed_at IS NULL AND NOT (shopify_id = ANY($2))
+	`, brandID, seen)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retire products: %w", err)
 	}
+	return int(tag.RowsAffected()), nil
+}
 
-	if err := tx.Commit(ctx); err != nil {
-		return false, fmt.Errorf("failed to commit transaction: %w", err)
+// CountLiveProducts returns how many of the brand's products are not retired
+func (db *DB) CountLiveProducts(ctx context.Context, brandID string) (int, error) {
+	var n int
+	err := db.pool.QueryRow(ctx,
+		"SELECT COUNT(*) FROM products WHERE brand_id = $1 AND removed_at IS NULL",
+		brandID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count products: %w", err)
 	}
+	return n, nil
+}
 
-	return wasCreated, nil
+// RetireMissingHandles is RetireMissingProducts for stores acquired from their sitemap,
+// where products are identified by handle rather than shopify_id
+func (db *DB) RetireMissingHandles(ctx context.Context, brandID string, handles []string) (int, error) {
+	tag, err := db.pool.Exec(ctx, `
+		UPDATE products
+		SET removed_at = NOW(), is_available = false, updated_at = NOW()
+		WHERE brand_id = $1 AND removed_at IS NULL AND NOT (slug = ANY($2))
+	`, brandID, handles)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retire products: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
 }
 
 // UpdateBrandLastSyncedAt updates the last synced timestamp for a brand
@@ -212,6 +373,57 @@ func (db *DB) UpdateBrandLastSyncedAt(ctx context.Context, brandID string) error
 	return err
 }
 
+// UpdateBrandCurrency stores the currency detected for a brand's store
+func (db *DB) UpdateBrandCurrency(ctx context.Context, brandID, currency string) error {
+	_, err := db.pool.Exec(ctx,
+		"UPDATE brands SET currency = $2, updated_at = NOW() WHERE id = $1",
+		brandID, currency)
+	return err
+}
+
+// UpdateBrandSyncCheckpoint stores the brand's updated_at high-water mark
+func (db *DB) UpdateBrandSyncCheckpoint(ctx context.Context, brandID string, checkpoint time.Time) error {
+	_, err := db.pool.Exec(ctx,
+		"UPDATE brands SET sync_checkpoint = $2, updated_at = NOW() WHERE id = $1",
+		brandID, checkpoint)
+	return err
+}
+
+// RecordFullSync records that a full reconciliation pass went through,
+// so the next one can be scheduled
+func (db *DB) RecordFullSync(ctx context.Context, brandID string) error {
+	_, err := db.pool.Exec(ctx,
+		"UPDATE brands SET last_full_sync_at = NOW(), updated_at = NOW() WHERE id = $1",
+		brandID)
+	return err
+}
+
+// SaveSyncCursor records how far a sync got, so an interrupted one resumes from the next
+// page instead of starting over. since is the run's updated_at filter, zero for a full pass.
+func (db *DB) SaveSyncCursor(ctx context.Context, brandID, cursor string, since time.Time, full bool) error {
+	var sinceArg *time.Time
+	if !since.IsZero() {
+		sinceArg = &since
+	}
+
+	_, err := db.pool.Exec(ctx, `
+		UPDATE brands
+		SET sync_cursor = $2, sync_cursor_since = $3, sync_cursor_full = $4
+		WHERE id = $1
+	`, brandID, cursor, sinceArg, full)
+	return err
+}
+
+// ClearSyncCursor forgets the resume point once a sync has finished
+func (db *DB) ClearSyncCursor(ctx context.Context, brandID string) error {
+	_, err := db.pool.Exec(ctx, `
+		UPDATE brands
+		SET sync_cursor = NULL, sync_cursor_since = NULL, sync_cursor_full = false
+		WHERE id = $1 AND sync_cursor IS NOT NULL
+	`, brandID)
+	return err
+}
+
 // CreateSyncLog creates a new sync log entry
 func (db *DB) CreateSyncLog(ctx context.Context, brandID, status string) (string, error) {
 	var id string
@@ -227,20 +439,54 @@ func (db *DB) UpdateSyncLog(ctx context.Context, logID string, result models.Syn
 	var errorMsg *string
 	if result.Error != nil {
 		status = "failed"
+		switch {
+		case result.Interrupted:
+			status = "interrupted"
+		case result.Blocked:
+			status = "blocked"
+		}
 		msg := result.Error.Error()
 		errorMsg = &msg
 	}
 
+	syncType := "full"
+	if result.Incremental {
+		syncType = "incremental"
+	}
+
 	_, err := db.pool.Exec(ctx, `
 		UPDATE sync_logs
 		SET status = $1, products_found = $2, products_created = $3, products_updated = $4,
-		    error_message = $5, completed_at = NOW()
-		WHERE id = $6
-	`, status, result.ProductsFound, result.ProductsCreated, result.ProductsUpdated, errorMsg, logID)
+		    products_unchanged = $5, products_removed = $6, products_restored = $7, error_message = $8,
+		    sync_type = $9, acquisition_mode = NULLIF($10, ''), completed_at = NOW()
+		WHERE id = $11
+	`, status, result.ProductsFound, result.ProductsCreated, result.ProductsUpdated, result.ProductsUnchanged,
+		result.ProductsRemoved, result.ProductsRestored, errorMsg, syncType, result.Mode, logID)
 
 	return err
 }
 
+// SweepOrphanedSyncLogs marks as interrupted the running sync logs whose brand no replica
+// holds a lease on, i.e. those of syncs whose process died, and returns how many it marked
+func (db *DB) SweepOrphanedSyncLogs(ctx context.Context) (int64, error) {
+	tag, err := db.pool.Exec(ctx, `
+		UPDATE sync_logs l
+		SET status = 'interrupted',
+		    error_message = COALESCE(l.error_message, 'scraper stopped before the sync finished'),
+		    completed_at = NOW()
+		WHERE l.status = 'running'
+		  AND NOT EXISTS (
+		      SELECT 1 FROM sync_leases sl
+		      WHERE sl.name = 'brand:' || l.brand_id AND sl.expires_at > NOW()
+		  )
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sweep sync logs: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
 // Pool returns the underlying connection pool (for testing/advanced usage)
 func (db *DB) Pool() *pgxpool.Pool {
 	return db.pool
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// hashVersion is mixed into every content hash. Bump it whenever the normalization
+// or the stored fields change so that every product gets rewritten once.
+const hashVersion = "v3"
+
+// hashedProduct is the normalized form of a product that goes into its content hash.
+// It holds only what UpsertProduct stores, so Shopify bumping updated_at alone
+// does not count as a change.
+type hashedProduct struct {
+	Currency    string                  `json:"currency"`
+	Title       string                  `json:"title"`
+	Handle      string                  `json:"handle"`
+	BodyHTML    string                  `json:"body_html"`
+	Vendor      string                  `json:"vendor"`
+	ProductType string                  `json:"product_type"`
+	Tags        []string                `json:"tags"`
+	PublishedAt time.Time               `json:"published_at"`
+	Variants    []models.ShopifyVariant `json:"variants"`
+	Images      []models.ShopifyImage   `json:"images"`
+}
+
+// contentHash returns a stable hash of a product's stored content
+func contentHash(sp models.ShopifyProduct, currency string) string {
+	tags := append([]string(nil), parseTags(sp.Tags)...)
+	sort.Strings(tags)
+
+	// Prices are hashed as numbers, since products.json and /products/{handle}.js
+	// format the same compare-at price differently
+	variants := append([]models.ShopifyVariant(nil), sp.Variants...)
+	for i := range variants {
+		price, cap := variantPrices(variants[i])
+		variants[i].Price = strconv.FormatFloat(price, 'f', -1, 64)
+		variants[i].CompareAtPrice = nil
+		if cap != nil {
+			c := strconv.FormatFloat(*cap, 'f', -1, 64)
+			variants[i].CompareAtPrice = &c
+		}
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return variantKey(variants[i].ID, variants[i].SKU, variants[i].Title) <
+			variantKey(variants[j].ID, variants[j].SKU, variants[j].Title)
+	})
+
+	images := append([]models.ShopifyImage(nil), sp.Images...)
+	sort.SliceStable(images, func(i, j int) bool {
+		if images[i].Position != images[j].Position {
+			return images[i].Position < images[j].Position
+		}
+		return imageKey(images[i].ID, images[i].Src) < imageKey(images[j].ID, images[j].Src)
+	})
+
+	data, _ := json.Marshal(hashedProduct{
+		Currency:    currency,
+		Title:       sp.Title,
+		Handle:      sp.Handle,
+		BodyHTML:    sp.BodyHTML,
+		Vendor:      sp.Vendor,
+		ProductType: sp.ProductType,
+		Tags:        tags,
+		PublishedAt: sp.PublishedAt.UTC(),
+		Variants:    variants,
+		Images:      images,
+	})
+
+	sum := sha256.Sum256(append([]byte(hashVersion+":"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseTags handles both the string and array formats Shopify stores use for tags,
+// and the list /products/{handle}.js gives
+func parseTags(raw interface{}) []string {
+	var tags []string
+	switch t := raw.(type) {
+	case string:
+		if t != "" {
+			tags = strings.Split(t, ", ")
+		}
+	case []string:
+		tags = append(tags, t...)
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+	}
+	return tags
+}
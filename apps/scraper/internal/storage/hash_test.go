@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+func strPtr(s string) *string { return &s }
+
+// hashFixture is a product with two variants and two images
+func hashFixture() models.ShopifyProduct {
+	return models.ShopifyProduct{
+		ID:          1,
+		Title:       "Mug",
+		Handle:      "mug",
+		Tags:        "ceramic, handmade",
+		PublishedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		Variants: []models.ShopifyVariant{
+			{ID: 10, Title: "Blue", Price: "12.50", CompareAtPrice: strPtr("15.00"), Available: true},
+			{ID: 11, Title: "Red", Price: "12.50", CompareAtPrice: strPtr("0.00")},
+		},
+		Images: []models.ShopifyImage{
+			{ID: 100, Src: "https://cdn.example/a.jpg", Position: 1},
+			{ID: 101, Src: "https://cdn.example/b.jpg", Position: 2},
+		},
+	}
+}
+
+func TestContentHashStable(t *testing.T) {
+	base := contentHash(hashFixture(), "EUR")
+
+	tests := []struct {
+		name   string
+		change func(p *models.ShopifyProduct)
+	}{
+		{"updated_at only", func(p *models.ShopifyProduct) { p.UpdatedAt = p.UpdatedAt.Add(time.Hour) }},
+		{"variant order", func(p *models.ShopifyProduct) { p.Variants[0], p.Variants[1] = p.Variants[1], p.Variants[0] }},
+		{"tag order and format", func(p *models.ShopifyProduct) { p.Tags = []interface{}{"handmade", "ceramic"} }},
+		{"tags from .js", func(p *models.ShopifyProduct) { p.Tags = []string{"ceramic", "handmade"} }},
+		{"price format", func(p *models.ShopifyProduct) { p.Variants[0].Price = "12.5" }},
+		{"compare-at format", func(p *models.ShopifyProduct) { p.Variants[0].CompareAtPrice = strPtr("15") }},
+		{"zero compare-at as none", func(p *models.ShopifyProduct) { p.Variants[1].CompareAtPrice = nil }},
+		{"published_at zone", func(p *models.ShopifyProduct) {
+			p.PublishedAt = p.PublishedAt.In(time.FixedZone("EST", -5*3600))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := hashFixture()
+			tt.change(&p)
+			if got := contentHash(p, "EUR"); got != base {
+				t.Fatal("hash changed")
+			}
+		})
+	}
+}
+
+func TestContentHashChanges(t *testing.T) {
+	base := contentHash(hashFixture(), "EUR")
+
+	tests := []struct {
+		name   string
+		change func(p *models.ShopifyProduct)
+	}{
+		{"title", func(p *models.ShopifyProduct) { p.Title = "Big mug" }},
+		{"price", func(p *models.ShopifyProduct) { p.Variants[0].Price = "13.00" }},
+		{"compare-at price", func(p *models.ShopifyProduct) { p.Variants[1].CompareAtPrice = strPtr("14.00") }},
+		{"availability", func(p *models.ShopifyProduct) { p.Variants[1].Available = true }},
+		{"inventory", func(p *models.ShopifyProduct) { p.Variants[0].InventoryQuantity = 3 }},
+		{"image", func(p *models.ShopifyProduct) { p.Images = p.Images[:1] }},
+		{"tags", func(p *models.ShopifyProduct) { p.Tags = "ceramic" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := hashFixture()
+			tt.change(&p)
+			if got := contentHash(p, "EUR"); got == base {
+				t.Fatal("hash didn't change")
+			}
+		})
+	}
+
+	if contentHash(hashFixture(), "USD") == base {
+		t.Error("hash didn't change with the currency")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	p := hashFixture()
+	p.Variants = append(p.Variants, models.ShopifyVariant{ID: 12, Price: "9.00", CompareAtPrice: strPtr("20.00")})
+
+	sum := summarize(p)
+	if sum.priceMin != 9 || sum.priceMax != 12.5 || !sum.isAvailable {
+		t.Fatalf("got range %v-%v, available %v; want 9-12.5, true", sum.priceMin, sum.priceMax, sum.isAvailable)
+	}
+	if sum.compareAtPrice == nil || *sum.compareAtPrice != 20 {
+		t.Fatalf("got compare-at price %v, want 20", sum.compareAtPrice)
+	}
+	if len(sum.tags) != 2 {
+		t.Fatalf("got tags %v, want 2", sum.tags)
+	}
+}
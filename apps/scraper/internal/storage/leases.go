@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AcquireLease takes the named lease for holder until ttl from now, or extends it when
+// holder already has it. It reports false while another holder's lease hasn't expired.
+func (db *DB) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	var got string
+	err := db.pool.QueryRow(ctx, `
+		INSERT INTO sync_leases (name, holder, acquired_at, expires_at)
+		VALUES ($1, $2, NOW(), NOW() + make_interval(secs => $3))
+		ON CONFLICT (name) DO UPDATE
+		SET holder = EXCLUDED.holder,
+		    acquired_at = CASE WHEN sync_leases.holder = EXCLUDED.holder
+		                       THEN sync_leases.acquired_at ELSE NOW() END,
+		    expires_at = EXCLUDED.expires_at
+		WHERE sync_leases.holder = EXCLUDED.holder OR sync_leases.expires_at < NOW()
+		RETURNING holder
+	`, name, holder, ttl.Seconds()).Scan(&got)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// ReleaseLease gives up a lease held by holder, so another replica can take it right away
+func (db *DB) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := db.pool.Exec(ctx,
+		"DELETE FROM sync_leases WHERE name = $1 AND holder = $2",
+		name, holder)
+	return err
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pricePoint is the latest recorded price of a variant
+type pricePoint struct {
+	price          float64
+	compareAtPrice *float64
+}
+
+// loadLatestPrices returns the latest recorded price of every variant of the given products,
+// keyed by product ID and then variant Shopify ID
+func loadLatestPrices(ctx context.Context, q querier, productIDs []string) (map[string]map[int64]pricePoint, error) {
+	rows, err := q.Query(ctx, `
+		SELECT DISTINCT ON (product_id, variant_shopify_id) product_id, variant_shopify_id, price, compare_at_price
+		FROM price_history
+		WHERE product_id = ANY($1)
+		ORDER BY product_id, variant_shopify_id, recorded_at DESC
+	`, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %w", err)
+	}
+	defer rows.Close()
+
+	latest := make(map[string]map[int64]pricePoint)
+	for rows.Next() {
+		var productID string
+		var variantID int64
+		var p pricePoint
+		if err := rows.Scan(&productID, &variantID, &p.price, &p.compareAtPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan price history: %w", err)
+		}
+		if latest[productID] == nil {
+			latest[productID] = make(map[int64]pricePoint)
+		}
+		latest[productID][variantID] = p
+	}
+
+	return latest, rows.Err()
+}
+
+// queuePriceChanges queues a price_history row for every variant whose price or
+// compare-at price differs from its latest recorded one. A variant seen for the
+// first time gets a baseline row so window queries have a starting price, which
+// isn't counted as a change.
+func queuePriceChanges(batch *pgx.Batch, productID string, latest map[int64]pricePoint, variants []models.ShopifyVariant) int {
+	changes := 0
+	for _, v := range variants {
+		price, cap := variantPrices(v)
+
+		prev, ok := latest[v.ID]
+		if ok && samePrice(&prev.price, &price) && samePrice(prev.compareAtPrice, cap) {
+			continue
+		}
+
+		batch.Queue(`
+			INSERT INTO price_history (product_id, variant_shopify_id, price, compare_at_price)
+			VALUES ($1, $2, $3, $4)
+		`, productID, v.ID, price, cap)
+		if ok {
+			changes++
+		}
+	}
+
+	return changes
+}
+
+// variantPrices parses a variant's price and optional compare-at price. A zero compare-at
+// price, as products.json gives for "0.00", means there is none, as in /products/{handle}.js.
+func variantPrices(v models.ShopifyVariant) (float64, *float64) {
+	price, _ := strconv.ParseFloat(v.Price, 64)
+	var cap *float64
+	if v.CompareAtPrice != nil && *v.CompareAtPrice != "" {
+		if c, err := strconv.ParseFloat(*v.CompareAtPrice, 64); err == nil && c > 0 {
+			cap = &c
+		}
+	}
+	return price, cap
+}
+
+// samePrice compares two optional prices to the cent
+func samePrice(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return math.Round(*a*100) == math.Round(*b*100)
+}
+
+// LowestPriceSince returns the lowest price any variant of a product had since the given time,
+// including the prices already in effect at that moment. This is the reference price for
+// honest discount claims (e.g. the lowest price of the last 30 days). It returns nil when
+// the product has no recorded price.
+func (db *DB) LowestPriceSince(ctx context.Context, productID string, since time.Time) (*float64, error) {
+	var lowest *float64
+	err := db.pool.QueryRow(ctx, `
+		SELECT MIN(price) FROM (
+			SELECT price FROM price_history
+			WHERE product_id = $1 AND recorded_at >= $2
+			UNION ALL
+			(SELECT DISTINCT ON (variant_shopify_id) price FROM price_history
+			 WHERE product_id = $1 AND recorded_at < $2
+			 ORDER BY variant_shopify_id, recorded_at DESC)
+		) AS window_prices
+	`, productID, since).Scan(&lowest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lowest price: %w", err)
+	}
+	return lowest, nil
+}
+
+// PriceDropsSince returns the variants of live products whose current price is lower than
+// the price they had at the given time, at most limit of them, steepest drops first.
+// Only variants with a price recorded since then are looked at, as the others can't have dropped.
+func (db *DB) PriceDropsSince(ctx context.Context, since time.Time, limit int) ([]models.PriceDrop, error) {
+	rows, err := db.pool.Query(ctx, `
+		WITH changed AS (
+			SELECT DISTINCT product_id, variant_shopify_id
+			FROM price_history
+			WHERE recorded_at > $1
+		), current_prices AS (
+			SELECT DISTINCT ON (h.product_id, h.variant_shopify_id) h.product_id, h.variant_shopify_id, h.price
+			FROM price_history h
+			JOIN changed USING (product_id, variant_shopify_id)
+			ORDER BY h.product_id, h.variant_shopify_id, h.recorded_at DESC
+		), previous_prices AS (
+			SELECT DISTINCT ON (h.product_id, h.variant_shopify_id) h.product_id, h.variant_shopify_id, h.price
+			FROM price_history h
+			JOIN changed USING (product_id, variant_shopify_id)
+			WHERE h.recorded_at <= $1
+			ORDER BY h.product_id, h.variant_shopify_id, h.recorded_at DESC
+		)
+		SELECT c.product_id, c.variant_shopify_id, p.price, c.price
+		FROM current_prices c
+		JOIN previous_prices p USING (product_id, variant_shopify_id)
+		JOIN products pr ON pr.id = c.product_id
+		WHERE c.price < p.price AND pr.removed_at IS NULL
+		ORDER BY (p.price - c.price) / p.price DESC
+		LIMIT $2
+	`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price drops: %w", err)
+	}
+	defer rows.Close()
+
+	var drops []models.PriceDrop
+	for rows.Next() {
+		var d models.PriceDrop
+		if err := rows.Scan(&d.ProductID, &d.VariantShopifyID, &d.PreviousPrice, &d.CurrentPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan price drop: %w", err)
+		}
+		drops = append(drops, d)
+	}
+
+	return drops, rows.Err()
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"testing"
+
+	"indie-marketplace/scraper/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestQueuePriceChanges(t *testing.T) {
+	cap := 15.0
+	latest := map[int64]pricePoint{
+		10: {price: 12.5, compareAtPrice: &cap},
+		11: {price: 12.5},
+	}
+	variants := []models.ShopifyVariant{
+		{ID: 10, Price: "12.50", CompareAtPrice: strPtr("15")},   // Unchanged
+		{ID: 11, Price: "10.00", CompareAtPrice: strPtr("0.00")}, // Price drop
+		{ID: 12, Price: "9.00"},                                  // New variant, baseline only
+	}
+
+	batch := &pgx.Batch{}
+	changes := queuePriceChanges(batch, "p", latest, variants)
+	if changes != 1 {
+		t.Fatalf("counted %d changes, want 1", changes)
+	}
+	if batch.Len() != 2 {
+		t.Fatalf("queued %d price_history rows, want 2", batch.Len())
+	}
+	for i, id := range []int64{11, 12} {
+		if got := batch.QueuedQueries[i].Arguments[1]; got != id {
+			t.Errorf("row %d is for variant %v, want %d", i, got, id)
+		}
+	}
+}
+
+func TestVariantPrices(t *testing.T) {
+	tests := []struct {
+		price, compareAt string
+		wantPrice        float64
+		wantCap          *float64
+	}{
+		{"12.50", "", 12.5, nil},
+		{"12.50", "0.00", 12.5, nil},
+		{"12.50", "not a price", 12.5, nil},
+		{"12.50", "15", 12.5, func() *float64 { c := 15.0; return &c }()},
+	}
+
+	for _, tt := range tests {
+		v := models.ShopifyVariant{Price: tt.price}
+		if tt.compareAt != "" {
+			v.CompareAtPrice = &tt.compareAt
+		}
+		price, cap := variantPrices(v)
+		if price != tt.wantPrice || !samePrice(cap, tt.wantCap) {
+			t.Errorf("variantPrices(%q, %q) = %v, %v; want %v, %v", tt.price, tt.compareAt, price, cap, tt.wantPrice, tt.wantCap)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"indie-marketplace/scraper/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// baseCurrency is the currency exchange rates are quoted against
+const baseCurrency = "EUR"
+
+// UpsertExchangeRates stores dated exchange rates, replacing any rate already stored for
+// the same currency and day. When rates repeats a currency and day, the last one wins.
+// It returns the number of rows inserted or changed.
+func (db *DB) UpsertExchangeRates(ctx context.Context, rates []models.ExchangeRate) (int, error) {
+	if len(rates) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMP TABLE exchange_rate_staging (
+			seq       integer,
+			currency  text,
+			rate_date date,
+			rate      numeric
+		) ON COMMIT DROP
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	rows := make([][]any, len(rates))
+	for i, r := range rates {
+		rows[i] = []any{i, r.Currency, r.Date, r.Rate}
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"exchange_rate_staging"},
+		[]string{"seq", "currency", "rate_date", "rate"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy exchange rates: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO exchange_rates (currency, rate_date, rate)
+		SELECT DISTINCT ON (currency, rate_date) currency, rate_date, rate
+		FROM exchange_rate_staging
+		ORDER BY currency, rate_date, seq DESC
+		ON CONFLICT (currency, rate_date) DO UPDATE SET rate = EXCLUDED.rate
+		WHERE exchange_rates.rate IS DISTINCT FROM EXCLUDED.rate
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to merge exchange rates: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// latestRate returns the most recent rate of a currency against EUR,
+// or nil when no rate is known for it
+func (db *DB) latestRate(ctx context.Context, currency string) (*float64, error) {
+	if currency == baseCurrency {
+		one := 1.0
+		return &one, nil
+	}
+
+	var rate float64
+	err := db.pool.QueryRow(ctx, `
+		SELECT rate FROM exchange_rates
+		WHERE currency = $1
+		ORDER BY rate_date DESC
+		LIMIT 1
+	`, currency).Scan(&rate)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exchange rate: %w", err)
+	}
+
+	return &rate, nil
+}
+
+// toEUR converts a price using a rate expressed in units of currency per EUR
+func toEUR(price float64, rate *float64) *float64 {
+	if rate == nil || *rate <= 0 {
+		return nil
+	}
+	eur := math.Round(price / *rate * 100) / 100
+	return &eur
+}
+
+// BackfillEURPrices recomputes price_min_eur and price_max_eur for every product from the
+// latest stored rates, clearing them for currencies without a usable rate so no stale
+// conversion survives. Run it after importing new rates, since unchanged products are
+// never rewritten by a sync. It returns the number of products updated.
+func (db *DB) BackfillEURPrices(ctx context.Context) (int64, error) {
+	tag, err := db.pool.Exec(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (currency) currency, rate
+			FROM exchange_rates
+			ORDER BY currency, rate_date DESC
+		), usable AS (
+			SELECT currency, rate FROM latest WHERE currency <> $1 AND rate > 0
+			UNION ALL
+			SELECT $1::varchar, 1::numeric
+		), converted AS (
+			SELECT p.id, ROUND(p.price_min / r.rate, 2) AS price_min_eur, ROUND(p.price_max / r.rate, 2) AS price_max_eur
+			FROM products p
+			LEFT JOIN usable r ON r.currency = COALESCE(p.currency, $1)
+		)
+		UPDATE products p
+		SET price_min_eur = c.price_min_eur,
+		    price_max_eur = c.price_max_eur
+		FROM converted c
+		WHERE c.id = p.id
+		  AND (p.price_min_eur IS DISTINCT FROM c.price_min_eur OR p.price_max_eur IS DISTINCT FROM c.price_max_eur)
+	`, baseCurrency)
+	if err != nil {
+		return 0, fmt.Errorf("failed to backfill EUR prices: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"indie-marketplace/scraper/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// querier is the read side shared by pgx.Tx and *pgxpool.Pool
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// writtenProduct links a product row that was just upserted to its source and result
+type writtenProduct struct {
+	id  string
+	sp  *models.ShopifyProduct
+	res *UpsertResult
+}
+
+// reconcileChildren brings the variants, images and price history of the given products
+// in line with their source, sending every resulting write in a single round trip
+func reconcileChildren(ctx context.Context, tx pgx.Tx, products []writtenProduct) error {
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.id
+	}
+
+	variants, err := loadVariants(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	images, err := loadImages(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+	prices, err := loadLatestPrices(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, p := range products {
+		p.res.VariantsChanged = queueVariantChanges(batch, p.id, variants[p.id], p.sp.Variants)
+		p.res.ImagesChanged = queueImageChanges(batch, p.id, images[p.id], p.sp.Images)
+		p.res.PriceChanges = queuePriceChanges(batch, p.id, prices[p.id], p.sp.Variants)
+	}
+
+	if batch.Len() > 0 {
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("failed to write variants and images: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// variantRow is a product_variants row as currently stored
+type variantRow struct {
+	id                string
+	shopifyID         int64
+	title             string
+	sku               string
+	price             float64
+	compareAtPrice    *float64
+	inventoryQuantity int
+	option1           string
+	option2           string
+	option3           string
+	isAvailable       bool
+}
+
+// imageRow is a product_images row as currently stored
+type imageRow struct {
+	id        string
+	shopifyID int64
+	src       string
+	altText   string
+	width     int
+	height    int
+	position  int
+}
+
+// variantKey matches a variant across syncs. Variants scraped from HTML have no
+// Shopify ID, so they fall back to their SKU and title.
+func variantKey(shopifyID int64, sku, title string) string {
+	if shopifyID != 0 {
+		return strconv.FormatInt(shopifyID, 10)
+	}
+	return "sku:" + sku + "|" + title
+}
+
+// imageKey matches an image across syncs, by Shopify ID or else by source URL
+func imageKey(shopifyID int64, src string) string {
+	if shopifyID != 0 {
+		return strconv.FormatInt(shopifyID, 10)
+	}
+	return "src:" + src
+}
+
+// loadVariants returns the stored variants of the given products, keyed by product ID
+func loadVariants(ctx context.Context, q querier, productIDs []string) (map[string][]variantRow, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, product_id, shopify_id, COALESCE(title, ''), COALESCE(sku, ''), price, compare_at_price,
+		       COALESCE(inventory_quantity, 0), COALESCE(option1, ''), COALESCE(option2, ''),
+		       COALESCE(option3, ''), COALESCE(is_available, false)
+		FROM product_variants
+		WHERE product_id = ANY($1)
+	`, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query variants: %w", err)
+	}
+	defer rows.Close()
+
+	variants := make(map[string][]variantRow)
+	for rows.Next() {
+		var v variantRow
+		var productID string
+		err := rows.Scan(&v.id, &productID, &v.shopifyID, &v.title, &v.sku, &v.price, &v.compareAtPrice,
+			&v.inventoryQuantity, &v.option1, &v.option2, &v.option3, &v.isAvailable)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan variant: %w", err)
+		}
+		variants[productID] = append(variants[productID], v)
+	}
+
+	return variants, rows.Err()
+}
+
+// loadImages returns the stored images of the given products, keyed by product ID
+func loadImages(ctx context.Context, q querier, productIDs []string) (map[string][]imageRow, error) {
+	rows, err := q.Query(ctx, `
+		SELECT id, product_id, COALESCE(shopify_id, 0), src, COALESCE(alt_text, ''),
+		       COALESCE(width, 0), COALESCE(height, 0), COALESCE(position, 0)
+		FROM product_images
+		WHERE product_id = ANY($1)
+	`, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query images: %w", err)
+	}
+	defer rows.Close()
+
+	images := make(map[string][]imageRow)
+	for rows.Next() {
+		var img imageRow
+		var productID string
+		err := rows.Scan(&img.id, &productID, &img.shopifyID, &img.src, &img.altText,
+			&img.width, &img.height, &img.position)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan image: %w", err)
+		}
+		images[productID] = append(images[productID], img)
+	}
+
+	return images, rows.Err()
+}
+
+// queueVariantChanges queues the statements that bring a product's stored variants in line
+// with the incoming ones: matched rows are updated in place only when something differs,
+// new ones are inserted and vanished ones deleted. It returns the number of rows touched.
+func queueVariantChanges(batch *pgx.Batch, productID string, existing []variantRow, incoming []models.ShopifyVariant) int {
+	stored := make(map[string]variantRow, len(existing))
+	var stale []string
+	for _, row := range existing {
+		key := variantKey(row.shopifyID, row.sku, row.title)
+		if _, dup := stored[key]; dup {
+			stale = append(stale, row.id)
+			continue
+		}
+		stored[key] = row
+	}
+
+	changed := 0
+	for _, v := range incoming {
+		price, cap := variantPrices(v)
+		key := variantKey(v.ID, v.SKU, v.Title)
+
+		row, ok := stored[key]
+		if !ok {
+			batch.Queue(`
+				INSERT INTO product_variants (product_id, shopify_id, title, sku, price, compare_at_price,
+				                              inventory_quantity, option1, option2, option3, is_available)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			`, productID, v.ID, v.Title, v.SKU, price, cap, v.InventoryQuantity,
+				v.Option1, v.Option2, v.Option3, v.Available)
+			changed++
+			continue
+		}
+		delete(stored, key)
+
+		if row.title == v.Title && row.sku == v.SKU &&
+			samePrice(&row.price, &price) && samePrice(row.compareAtPrice, cap) &&
+			row.inventoryQuantity == v.InventoryQuantity && row.option1 == v.Option1 &&
+			row.option2 == v.Option2 && row.option3 == v.Option3 && row.isAvailable == v.Available {
+			continue
+		}
+
+		batch.Queue(`
+			UPDATE product_variants
+			SET title = $2, sku = $3, price = $4, compare_at_price = $5, inventory_quantity = $6,
+			    option1 = $7, option2 = $8, option3 = $9, is_available = $10
+			WHERE id = $1
+		`, row.id, v.Title, v.SKU, price, cap, v.InventoryQuantity,
+			v.Option1, v.Option2, v.Option3, v.Available)
+		changed++
+	}
+
+	for _, row := range stored {
+		stale = append(stale, row.id)
+	}
+	if len(stale) > 0 {
+		batch.Queue("DELETE FROM product_variants WHERE id = ANY($1)", stale)
+		changed += len(stale)
+	}
+
+	return changed
+}
+
+// queueImageChanges is the product_images counterpart of queueVariantChanges
+func queueImageChanges(batch *pgx.Batch, productID string, existing []imageRow, incoming []models.ShopifyImage) int {
+	stored := make(map[string]imageRow, len(existing))
+	var stale []string
+	for _, row := range existing {
+		key := imageKey(row.shopifyID, row.src)
+		if _, dup := stored[key]; dup {
+			stale = append(stale, row.id)
+			continue
+		}
+		stored[key] = row
+	}
+
+	changed := 0
+	for _, img := range incoming {
+		key := imageKey(img.ID, img.Src)
+
+		row, ok := stored[key]
+		if !ok {
+			batch.Queue(`
+				INSERT INTO product_images (product_id, shopify_id, src, alt_text, width, height, position)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+			`, productID, img.ID, img.Src, img.Alt, img.Width, img.Height, img.Position)
+			changed++
+			continue
+		}
+		delete(stored, key)
+
+		if row.src == img.Src && row.altText == img.Alt && row.width == img.Width &&
+			row.height == img.Height && row.position == img.Position {
+			continue
+		}
+
+		batch.Queue(`
+			UPDATE product_images
+			SET src = $2, alt_text = $3, width = $4, height = $5, position = $6
+			WHERE id = $1
+		`, row.id, img.Src, img.Alt, img.Width, img.Height, img.Position)
+		changed++
+	}
+
+	for _, row := range stored {
+		stale = append(stale, row.id)
+	}
+	if len(stale) > 0 {
+		batch.Queue("DELETE FROM product_images WHERE id = ANY($1)", stale)
+		changed += len(stale)
+	}
+
+	return changed
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"indie-marketplace/scraper/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// statements returns the first word of each queued statement
+func statements(batch *pgx.Batch) []string {
+	var verbs []string
+	for _, q := range batch.QueuedQueries {
+		verbs = append(verbs, strings.Fields(q.SQL)[0])
+	}
+	return verbs
+}
+
+func TestQueueVariantChanges(t *testing.T) {
+	cap := 15.0
+	existing := []variantRow{
+		{id: "a", shopifyID: 10, title: "Blue", price: 12.5, compareAtPrice: &cap, isAvailable: true},
+		{id: "b", shopifyID: 11, title: "Red", price: 12.5},
+		{id: "c", shopifyID: 12, title: "Green", price: 12.5},
+		{id: "d", shopifyID: 12, title: "Green", price: 12.5}, // Duplicate row left by an old bug
+	}
+
+	tests := []struct {
+		name     string
+		incoming []models.ShopifyVariant
+		want     []string
+	}{
+		{
+			name: "unchanged",
+			incoming: []models.ShopifyVariant{
+				{ID: 10, Title: "Blue", Price: "12.50", CompareAtPrice: strPtr("15"), Available: true},
+				{ID: 11, Title: "Red", Price: "12.5", CompareAtPrice: strPtr("0.00")},
+				{ID: 12, Title: "Green", Price: "12.50"},
+			},
+			want: []string{"DELETE"},
+		},
+		{
+			name: "price changed, variant added and removed",
+			incoming: []models.ShopifyVariant{
+				{ID: 10, Title: "Blue", Price: "11.00", CompareAtPrice: strPtr("15.00"), Available: true},
+				{ID: 11, Title: "Red", Price: "12.50"},
+				{ID: 13, Title: "Black", Price: "12.50"},
+			},
+			want: []string{"UPDATE", "INSERT", "DELETE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := &pgx.Batch{}
+			changed := queueVariantChanges(batch, "p", existing, tt.incoming)
+
+			got := statements(batch)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("queued %v, want %v", got, tt.want)
+			}
+
+			// The DELETE removes every stale row at once
+			deleted := batch.QueuedQueries[len(got)-1].Arguments[0].([]string)
+			if changed != len(got)-1+len(deleted) {
+				t.Fatalf("reported %d changes for %v deleting %v", changed, got, deleted)
+			}
+		})
+	}
+}
+
+func TestQueueVariantChangesWithoutIDs(t *testing.T) {
+	// Variants scraped without an ID are matched by SKU and title
+	existing := []variantRow{{id: "a", sku: "MUG-1", title: "Blue", price: 12.5, isAvailable: true}}
+	incoming := []models.ShopifyVariant{{SKU: "MUG-1", Title: "Blue", Price: "12.50", Available: true}}
+
+	batch := &pgx.Batch{}
+	if changed := queueVariantChanges(batch, "p", existing, incoming); changed != 0 || batch.Len() != 0 {
+		t.Fatalf("queued %v for an unchanged variant", statements(batch))
+	}
+}
+
+func TestQueueImageChanges(t *testing.T) {
+	existing := []imageRow{
+		{id: "a", shopifyID: 100, src: "https://cdn.example/a.jpg", position: 1},
+		{id: "b", src: "https://cdn.example/b.jpg", position: 2},
+	}
+	incoming := []models.ShopifyImage{
+		{ID: 100, Src: "https://cdn.example/a.jpg?v=2", Position: 1},
+		{Src: "https://cdn.example/c.jpg", Position: 2},
+	}
+
+	batch := &pgx.Batch{}
+	changed := queueImageChanges(batch, "p", existing, incoming)
+	if got := strings.Join(statements(batch), " "); got != "UPDATE INSERT DELETE" || changed != 3 {
+		t.Fatalf("queued %s with %d changes, want UPDATE INSERT DELETE with 3", got, changed)
+	}
+}
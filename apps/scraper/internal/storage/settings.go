@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pausedSetting is the scraper_settings key recording whether syncing is paused
+const pausedSetting = "paused"
+
+// IsPaused reports whether syncing is paused on every replica
+func (db *DB) IsPaused(ctx context.Context) (bool, error) {
+	var value string
+	err := db.pool.QueryRow(ctx,
+		"SELECT value FROM scraper_settings WHERE key = $1",
+		pausedSetting).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get pause setting: %w", err)
+	}
+
+	paused, _ := strconv.ParseBool(value)
+	return paused, nil
+}
+
+// SetPaused pauses or resumes syncing on every replica
+func (db *DB) SetPaused(ctx context.Context, paused bool) error {
+	_, err := db.pool.Exec(ctx, `
+		INSERT INTO scraper_settings (key, value, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET value = EXCLUDED.value, updated_at = NOW()
+	`, pausedSetting, strconv.FormatBool(paused))
+	if err != nil {
+		return fmt.Errorf("failed to set pause setting: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// RecentSyncActivity returns what the last completed syncs of a brand changed, newest
+// first, leaving out syncs started before since when it isn't zero
+func (db *DB) RecentSyncActivity(ctx context.Context, brandID string, since time.Time, limit int) ([]models.SyncActivity, error) {
+	rows, err := db.pool.Query(ctx, `
+		SELECT started_at, COALESCE(products_created, 0), COALESCE(products_updated, 0),
+		       COALESCE(products_removed, 0)
+		FROM sync_logs
+		WHERE brand_id = $1 AND status = 'completed' AND started_at >= $2
+		ORDER BY started_at DESC
+		LIMIT $3
+	`, brandID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync logs: %w", err)
+	}
+	defer rows.Close()
+
+	var activity []models.SyncActivity
+	for rows.Next() {
+		var a models.SyncActivity
+		if err := rows.Scan(&a.StartedAt, &a.Created, &a.Updated, &a.Removed); err != nil {
+			return nil, fmt.Errorf("failed to scan sync log: %w", err)
+		}
+		activity = append(activity, a)
+	}
+
+	return activity, nil
+}
+
+// UpdateBrandSyncInterval stores the interval learnt for a brand and why it was chosen
+func (db *DB) UpdateBrandSyncInterval(ctx context.Context, brandID string, interval time.Duration, reason string) error {
+	_, err := db.pool.Exec(ctx, `
+		UPDATE brands
+		SET sync_interval_seconds = $2, sync_interval_reason = $3, sync_interval_set_at = NOW()
+		WHERE id = $1
+	`, brandID, int(interval.Seconds()), reason)
+	return err
+}
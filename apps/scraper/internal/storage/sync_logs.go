@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// SyncLogFilter narrows ListSyncLogs; zero fields don't filter
+type SyncLogFilter struct {
+	BrandID string
+	Status  string
+	Since   time.Time // Runs started at or after Since
+	Limit   int       // 50 when 0
+}
+
+// ListSyncLogs returns the sync runs matching filter, newest first
+func (db *DB) ListSyncLogs(ctx context.Context, filter SyncLogFilter) ([]models.SyncLog, error) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.BrandID != "" {
+		add("l.brand_id = $%d", filter.BrandID)
+	}
+	if filter.Status != "" {
+		add("l.status = $%d", filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		add("l.started_at >= $%d", filter.Since)
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	args = append(args, limit)
+
+	rows, err := db.pool.Query(ctx, fmt.Sprintf(`
+		SELECT l.id, l.brand_id, b.name, l.status, l.sync_type, l.acquisition_mode,
+		       COALESCE(l.products_found, 0), COALESCE(l.products_created, 0),
+		       COALESCE(l.products_updated, 0), COALESCE(l.products_unchanged, 0),
+		       COALESCE(l.products_removed, 0), COALESCE(l.products_restored, 0),
+		       l.error_message, l.started_at, l.completed_at
+		FROM sync_logs l
+		JOIN brands b ON b.id = l.brand_id
+		%s
+		ORDER BY l.started_at DESC
+		LIMIT $%d
+	`, where, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []models.SyncLog
+	for rows.Next() {
+		var l models.SyncLog
+		err := rows.Scan(
+			&l.ID, &l.BrandID, &l.BrandName, &l.Status, &l.SyncType, &l.AcquisitionMode,
+			&l.ProductsFound, &l.ProductsCreated, &l.ProductsUpdated, &l.ProductsUnchanged,
+			&l.ProductsRemoved, &l.ProductsRestored, &l.ErrorMessage, &l.StartedAt, &l.CompletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sync log: %w", err)
+		}
+		logs = append(logs, l)
+	}
+
+	return logs, nil
+}
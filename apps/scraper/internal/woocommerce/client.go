@@ -0,0 +1,137 @@
+package woocommerce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"indie-marketplace/scraper/internal/fetcher"
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// pageSize is the largest page the Store API serves
+const pageSize = 100
+
+// maxPages caps how many pages are read per listing, in case a store
+// keeps serving the last page for out of range page numbers
+const maxPages = 200
+
+// Client reads a WooCommerce store's public Store API (/wp-json/wc/store/v1)
+type Client struct {
+	fetcher *fetcher.Fetcher
+}
+
+// NewClient creates a new WooCommerce client on top of a shared fetcher
+func NewClient(f *fetcher.Fetcher) *Client {
+	return &Client{fetcher: f}
+}
+
+// FetchProductPages streams the catalog of a store one listing page at a time. Variable
+// products are listed without variation prices, so the variations of each page are read
+// with a second request and attached to their parent as variants. An error from fn stops
+// the fetch and is returned as is. It reports whether the fetch reached the last page
+// rather than stopping at maxPages.
+func (c *Client) FetchProductPages(ctx context.Context, domain string, fn func(products []models.ShopifyProduct) error) (bool, error) {
+	for page := 1; page <= maxPages; page++ {
+		pageURL := fmt.Sprintf("https://%s/wp-json/wc/store/v1/products?per_page=%d&page=%d", domain, pageSize, page)
+		parents, totalPages, err := c.fetchPage(ctx, pageURL)
+		if err != nil {
+			return false, err
+		}
+
+		if len(parents) == 0 {
+			return true, nil
+		}
+
+		variations, err := c.fetchVariations(ctx, domain, parents)
+		if err != nil {
+			return false, fmt.Errorf("failed to fetch variations: %w", err)
+		}
+
+		products := make([]models.ShopifyProduct, 0, len(parents))
+		for _, p := range parents {
+			products = append(products, p.toModel(variations[p.ID]))
+		}
+
+		if err := fn(products); err != nil {
+			return false, err
+		}
+
+		if len(parents) < pageSize || (totalPages > 0 && page >= totalPages) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// fetchVariations reads the variations of the variable products among parents, by parent ID
+func (c *Client) fetchVariations(ctx context.Context, domain string, parents []storeProduct) (map[int64][]storeProduct, error) {
+	var ids []string
+	for _, p := range parents {
+		if p.Type == "variable" {
+			ids = append(ids, strconv.FormatInt(p.ID, 10))
+		}
+	}
+
+	variations := make(map[int64][]storeProduct)
+	if len(ids) == 0 {
+		return variations, nil
+	}
+
+	for page := 1; page <= maxPages; page++ {
+		pageURL := fmt.Sprintf("https://%s/wp-json/wc/store/v1/products?type=variation&parent=%s&per_page=%d&page=%d",
+			domain, strings.Join(ids, ","), pageSize, page)
+		products, totalPages, err := c.fetchPage(ctx, pageURL)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range products {
+			variations[v.Parent] = append(variations[v.Parent], v)
+		}
+
+		if len(products) < pageSize || (totalPages > 0 && page >= totalPages) {
+			break
+		}
+	}
+
+	return variations, nil
+}
+
+// FetchCurrency returns the currency a store prices its catalog in
+func (c *Client) FetchCurrency(ctx context.Context, domain string) (string, error) {
+	page, _, err := c.fetchPage(ctx, fmt.Sprintf("https://%s/wp-json/wc/store/v1/products?per_page=1", domain))
+	if err != nil {
+		return "", err
+	}
+	if len(page) == 0 || page[0].Prices.CurrencyCode == "" {
+		return "", fmt.Errorf("could not detect currency for %s", domain)
+	}
+
+	return page[0].Prices.CurrencyCode, nil
+}
+
+// fetchPage fetches one listing page and the X-WP-TotalPages header, 0 when absent
+func (c *Client) fetchPage(ctx context.Context, pageURL string) ([]storeProduct, int, error) {
+	resp, err := c.fetcher.Get(ctx, pageURL, "application/json")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fetcher.NewStatusError(resp)
+	}
+
+	var products []storeProduct
+	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	totalPages, _ := strconv.Atoi(resp.Header.Get("X-WP-TotalPages"))
+	return products, totalPages, nil
+}
@@ -0,0 +1,211 @@
+package woocommerce
+
+import (
+	"html"
+	"math"
+	"strconv"
+	"strings"
+
+	"indie-marketplace/scraper/pkg/models"
+)
+
+// storeProduct is a product or variation as served by /wp-json/wc/store/v1/products
+type storeProduct struct {
+	ID                int64            `json:"id"`
+	Name              string           `json:"name"`
+	Slug              string           `json:"slug"`
+	Parent            int64            `json:"parent"`
+	Type              string           `json:"type"`
+	SKU               string           `json:"sku"`
+	Description       string           `json:"description"`
+	ShortDescription  string           `json:"short_description"`
+	OnSale            bool             `json:"on_sale"`
+	Prices            storePrices      `json:"prices"`
+	Images            []storeImage     `json:"images"`
+	Categories        []storeTerm      `json:"categories"`
+	Tags              []storeTerm      `json:"tags"`
+	Attributes        []storeAttribute `json:"attributes"`
+	Variations        []storeVariation `json:"variations"`
+	IsInStock         bool             `json:"is_in_stock"`
+	LowStockRemaining *int             `json:"low_stock_remaining"`
+}
+
+// storePrices holds amounts as integer strings in the currency's minor unit
+type storePrices struct {
+	Price             string `json:"price"`
+	RegularPrice      string `json:"regular_price"`
+	CurrencyCode      string `json:"currency_code"`
+	CurrencyMinorUnit int    `json:"currency_minor_unit"`
+}
+
+type storeImage struct {
+	ID  int64  `json:"id"`
+	Src string `json:"src"`
+	Alt string `json:"alt"`
+}
+
+type storeTerm struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type storeAttribute struct {
+	ID            int64       `json:"id"`
+	Name          string      `json:"name"`
+	Taxonomy      string      `json:"taxonomy"`
+	HasVariations bool        `json:"has_variations"`
+	Terms         []storeTerm `json:"terms"`
+}
+
+// storeVariation is a reference from a variable product to one of its variations
+type storeVariation struct {
+	ID         int64 `json:"id"`
+	Attributes []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"attributes"`
+}
+
+// toModel maps a Store API product and its variations to the internal product model
+func (p storeProduct) toModel(variations []storeProduct) models.ShopifyProduct {
+	sp := models.ShopifyProduct{
+		ID:       p.ID,
+		Title:    html.UnescapeString(p.Name),
+		Handle:   p.Slug,
+		BodyHTML: p.Description,
+	}
+	if sp.BodyHTML == "" {
+		sp.BodyHTML = p.ShortDescription
+	}
+	if len(p.Categories) > 0 {
+		sp.ProductType = html.UnescapeString(p.Categories[0].Name)
+	}
+
+	tags := make([]interface{}, 0, len(p.Tags))
+	for _, t := range p.Tags {
+		tags = append(tags, html.UnescapeString(t.Name))
+	}
+	sp.Tags = tags
+
+	for i, img := range p.Images {
+		sp.Images = append(sp.Images, models.ShopifyImage{
+			ID:        img.ID,
+			ProductID: p.ID,
+			Src:       img.Src,
+			Alt:       img.Alt,
+			Position:  i + 1,
+		})
+	}
+
+	// Only attributes used for variations become options, at most three like Shopify
+	var options []storeAttribute
+	for _, a := range p.Attributes {
+		if a.HasVariations && len(options) < 3 {
+			options = append(options, a)
+		}
+	}
+	for i, a := range options {
+		values := make([]string, len(a.Terms))
+		for j, t := range a.Terms {
+			values[j] = html.UnescapeString(t.Name)
+		}
+		sp.Options = append(sp.Options, models.ShopifyOption{
+			ID:        a.ID,
+			ProductID: p.ID,
+			Name:      html.UnescapeString(a.Name),
+			Position:  i + 1,
+			Values:    values,
+		})
+	}
+
+	if len(p.Variations) == 0 {
+		v := p.toVariant(p.ID)
+		v.Title = "Default Title"
+		sp.Variants = append(sp.Variants, v)
+		return sp
+	}
+
+	byID := make(map[int64]storeProduct, len(variations))
+	for _, v := range variations {
+		byID[v.ID] = v
+	}
+
+	for _, ref := range p.Variations {
+		// Variations missing from the second pass keep the parent's (lowest) price
+		source, ok := byID[ref.ID]
+		if !ok {
+			source = p
+		}
+		v := source.toVariant(p.ID)
+		v.ID = ref.ID
+
+		values := make([]string, len(options))
+		for _, attr := range ref.Attributes {
+			for i, o := range options {
+				if attr.Name == o.Name || attr.Name == o.Taxonomy {
+					values[i] = termName(o, attr.Value)
+				}
+			}
+		}
+		for i, value := range values {
+			switch i {
+			case 0:
+				v.Option1 = value
+			case 1:
+				v.Option2 = value
+			case 2:
+				v.Option3 = value
+			}
+		}
+		v.Title = strings.Join(values, " / ")
+
+		sp.Variants = append(sp.Variants, v)
+	}
+
+	return sp
+}
+
+// toVariant builds a variant from the prices and stock of a product or variation
+func (p storeProduct) toVariant(productID int64) models.ShopifyVariant {
+	v := models.ShopifyVariant{
+		ID:        p.ID,
+		ProductID: productID,
+		SKU:       p.SKU,
+		Price:     minorToDecimal(p.Prices.Price, p.Prices.CurrencyMinorUnit),
+		Available: p.IsInStock,
+	}
+
+	if p.OnSale && p.Prices.RegularPrice != p.Prices.Price {
+		regular := minorToDecimal(p.Prices.RegularPrice, p.Prices.CurrencyMinorUnit)
+		v.CompareAtPrice = &regular
+	}
+	if p.LowStockRemaining != nil {
+		v.InventoryQuantity = *p.LowStockRemaining
+	}
+
+	return v
+}
+
+// termName resolves a variation attribute value, which is a term slug, to the term's name.
+// An empty value means the variation applies to any term.
+func termName(a storeAttribute, value string) string {
+	if value == "" {
+		return "Any"
+	}
+	for _, t := range a.Terms {
+		if t.Slug == value {
+			return html.UnescapeString(t.Name)
+		}
+	}
+	return value
+}
+
+// minorToDecimal converts an amount in minor units ("1999") to a decimal string ("19.99")
+func minorToDecimal(amount string, minorUnit int) string {
+	n, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(n)/math.Pow10(minorUnit), 'f', minorUnit, 64)
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"hash/fnv"
+	"time"
+)
 
 // ShopifyProduct represents a product from the Shopify API
 type ShopifyProduct struct {
@@ -17,6 +20,18 @@ type ShopifyProduct struct {
 	CreatedAt   time.Time        `json:"created_at"`
 	UpdatedAt   time.Time        `json:"updated_at"`
 	Options     []ShopifyOption  `json:"options"`
+
+	CanonicalURL     string `json:"-"` // Set by sources that key products by URL rather than a platform ID
+	InventoryUnknown bool   `json:"-"` // The source couldn't read inventory this time, so the stored quantities are kept
+	HandleKeyed      bool   `json:"-"` // IDs were derived from the handle, so a product stored under the same handle keeps its own
+}
+
+// SyntheticID derives a stable product ID from a key such as a handle or canonical URL,
+// for products scraped from pages that don't expose their Shopify ID
+func SyntheticID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64() & (1<<63 - 1))
 }
 
 // ShopifyVariant represents a product variant from Shopify
@@ -61,18 +76,36 @@ type ShopifyProductsResponse struct {
 
 // Brand represents a brand in our database
 type Brand struct {
-	ID            string     `json:"id"`
-	Name          string     `json:"name"`
-	Slug          string     `json:"slug"`
-	Description   *string    `json:"description"`
-	LogoURL       *string    `json:"logo_url"`
-	WebsiteURL    string     `json:"website_url"`
-	ShopifyDomain string     `json:"shopify_domain"`
-	Country       *string    `json:"country"`
-	IsActive      bool       `json:"is_active"`
-	LastSyncedAt  *time.Time `json:"last_synced_at"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	ID                 string     `json:"id"`
+	Name               string     `json:"name"`
+	Slug               string     `json:"slug"`
+	Description        *string    `json:"description"`
+	LogoURL            *string    `json:"logo_url"`
+	WebsiteURL         string     `json:"website_url"`
+	ShopifyDomain      string     `json:"shopify_domain"`   // Store host, whatever the platform
+	MyshopifyDomain    *string    `json:"myshopify_domain"` // Permanent xxx.myshopify.com domain, when the store revealed it
+	Platform           string     `json:"platform"`         // One of the Platform* constants
+	CrawlStartURL      *string    `json:"crawl_start_url"`  // Where the generic crawler starts, the home page when nil
+	SitemapURL         *string    `json:"sitemap_url"`      // Sitemap seeding the generic crawler
+	Country            *string    `json:"country"`
+	Currency           *string    `json:"currency"` // ISO 4217 code detected from the store
+	IsActive           bool       `json:"is_active"`
+	LastSyncedAt       *time.Time `json:"last_synced_at"`
+	SyncCheckpoint     *time.Time `json:"sync_checkpoint"` // Highest Shopify updated_at seen, for incremental syncs
+	LastFullSyncAt     *time.Time `json:"last_full_sync_at"`
+	SyncCursor         *string    `json:"sync_cursor"`           // Where an interrupted sync resumes, nil when the last one finished
+	SyncCursorSince    *time.Time `json:"sync_cursor_since"`     // updated_at filter of the interrupted sync, nil for a full pass
+	SyncCursorFull     bool       `json:"sync_cursor_full"`      // The interrupted sync was a full pass
+	SyncSchedule       *string    `json:"sync_schedule"`         // Cron expression with seconds, the scheduler's default when nil
+	SyncPriority       int        `json:"sync_priority"`         // Higher goes first when brands are due at the same time
+	SyncInterval       *int       `json:"sync_interval_seconds"` // Seconds between syncs learnt from how often the brand changes, used when it has no schedule
+	SyncIntervalReason *string    `json:"sync_interval_reason"`  // Why the interval was last changed
+	SyncIntervalSetAt  *time.Time `json:"sync_interval_set_at"`
+	Status             string     `json:"status"`        // One of the BrandStatus* constants
+	StatusReason       *string    `json:"status_reason"` // One of the Failure* constants while failing or deactivated
+	Failures           int        `json:"consecutive_failures"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
 
 // Product represents a product in our database
@@ -89,18 +122,109 @@ type Product struct {
 	PriceMin       float64    `json:"price_min"`
 	PriceMax       float64    `json:"price_max"`
 	Currency       string     `json:"currency"`
+	PriceMinEUR    *float64   `json:"price_min_eur"` // Converted with the latest known rate
+	PriceMaxEUR    *float64   `json:"price_max_eur"`
 	CompareAtPrice *float64   `json:"compare_at_price"`
 	IsAvailable    bool       `json:"is_available"`
 	PublishedAt    *time.Time `json:"published_at"`
+	RemovedAt      *time.Time `json:"removed_at"` // Set when the store stopped listing the product
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
+// PriceDrop is a variant whose current price is lower than at some earlier point
+type PriceDrop struct {
+	ProductID        string  `json:"product_id"`
+	VariantShopifyID int64   `json:"variant_shopify_id"`
+	PreviousPrice    float64 `json:"previous_price"`
+	CurrentPrice     float64 `json:"current_price"`
+}
+
+// SyncActivity is what one completed sync of a brand changed
+type SyncActivity struct {
+	StartedAt time.Time `json:"started_at"`
+	Created   int       `json:"products_created"`
+	Updated   int       `json:"products_updated"`
+	Removed   int       `json:"products_removed"`
+}
+
+// SyncLog is a recorded sync run of a brand
+type SyncLog struct {
+	ID                string     `json:"id"`
+	BrandID           string     `json:"brand_id"`
+	BrandName         string     `json:"brand_name"`
+	Status            string     `json:"status"`
+	SyncType          *string    `json:"sync_type"`
+	AcquisitionMode   *string    `json:"acquisition_mode"`
+	ProductsFound     int        `json:"products_found"`
+	ProductsCreated   int        `json:"products_created"`
+	ProductsUpdated   int        `json:"products_updated"`
+	ProductsUnchanged int        `json:"products_unchanged"`
+	ProductsRemoved   int        `json:"products_removed"`
+	ProductsRestored  int        `json:"products_restored"`
+	ErrorMessage      *string    `json:"error_message"`
+	StartedAt         time.Time  `json:"started_at"`
+	CompletedAt       *time.Time `json:"completed_at"`
+}
+
+// ExchangeRate is a reference rate for one day, in units of Currency per euro
+type ExchangeRate struct {
+	Currency string    `json:"currency"`
+	Date     time.Time `json:"date"`
+	Rate     float64   `json:"rate"`
+}
+
+// Storefront platforms a brand can run on
+const (
+	PlatformShopify     = "shopify"
+	PlatformWooCommerce = "woocommerce"
+	PlatformGeneric     = "generic" // Any site publishing schema.org Product JSON-LD, read by crawling
+)
+
+// Brand statuses, kept by the scraper from the outcome of each sync
+const (
+	BrandStatusPending     = "pending"     // Added but not trial-synced yet
+	BrandStatusActive      = "active"      // Last sync went through
+	BrandStatusFailing     = "failing"     // Recent syncs failed, see StatusReason
+	BrandStatusDeactivated = "deactivated" // Too many consecutive failures, no longer synced
+)
+
+// Reasons a sync failed, recorded as the brand's status reason
+const (
+	FailurePasswordPage = "password_page" // The store hides behind its password page
+	FailureNotShopify   = "not_shopify"   // The site isn't, or no longer is, on Shopify
+	FailureDNS          = "dns"           // The domain doesn't resolve, e.g. it lapsed
+	FailureTLS          = "tls"           // Broken or expired certificate
+	FailureBotWall      = "bot_wall"      // An anti-bot challenge answered instead of the store
+	FailureRobots       = "robots"        // robots.txt disallows what we need
+	FailureOther        = "error"
+)
+
+// Acquisition modes recorded on sync logs
+const (
+	AcquisitionJSON     = "products_json" // The store's /products.json endpoint
+	AcquisitionSitemap  = "sitemap"       // Product pages listed in the sitemap, when products.json is blocked
+	AcquisitionHTML     = "html"          // Product pages linked from /collections/all, when there is no sitemap either
+	AcquisitionStoreAPI = "store_api"     // The WooCommerce Store API
+	AcquisitionCrawl    = "crawl"         // schema.org JSON-LD of pages found by crawling the site
+)
+
 // SyncResult represents the result of a sync operation
 type SyncResult struct {
-	BrandID         string
-	ProductsFound   int
-	ProductsCreated int
-	ProductsUpdated int
-	Error           error
+	BrandID           string
+	ProductsFound     int
+	ProductsCreated   int
+	ProductsUpdated   int
+	ProductsUnchanged int    // Content hash matched, nothing written
+	ProductsRemoved   int    // Retired because the store no longer lists them
+	ProductsRestored  int    // Previously retired, listed again
+	ProductsEnriched  int    // Variant availability and inventory filled from /products/{handle}.js
+	PriceChanges      int    // Variants whose price or compare-at price changed
+	VariantsChanged   int    // Variant rows inserted, updated or deleted
+	ImagesChanged     int    // Image rows inserted, updated or deleted
+	Incremental       bool   // Only products changed since the brand's checkpoint were fetched
+	Mode              string // How the products were acquired, one of the Acquisition* constants
+	Blocked           bool   // The store's robots.txt disallows the pages we need
+	Interrupted       bool   // Cut short by shutdown
+	Error             error
 }
@@ -5,6 +5,7 @@ import {
   text,
   boolean,
   timestamp,
+  date,
   decimal,
   integer,
   bigint,
@@ -24,18 +25,55 @@ export const brands = pgTable(
     description: text("description"),
     logoUrl: varchar("logo_url", { length: 500 }),
     websiteUrl: varchar("website_url", { length: 500 }).notNull(),
-    shopifyDomain: varchar("shopify_domain", { length: 255 }).unique().notNull(),
+    shopifyDomain: varchar("shopify_domain", { length: 255 }).unique().notNull(), // store host, whatever the platform
+    myshopifyDomain: varchar("myshopify_domain", { length: 255 }), // permanent xxx.myshopify.com domain, found when the brand is added
+    platform: varchar("platform", { length: 50 }).default("shopify").notNull(), // 'shopify', 'woocommerce', 'generic'
+    crawlStartUrl: varchar("crawl_start_url", { length: 500 }), // generic crawler start page, home page when null
+    sitemapUrl: varchar("sitemap_url", { length: 500 }), // sitemap seeding the generic crawler
     country: varchar("country", { length: 100 }),
+    currency: varchar("currency", { length: 3 }), // detected from the store by the scraper
     createdAt: timestamp("created_at", { withTimezone: true }).defaultNow(),
     updatedAt: timestamp("updated_at", { withTimezone: true }).defaultNow(),
     lastSyncedAt: timestamp("last_synced_at", { withTimezone: true }),
+    syncCheckpoint: timestamp("sync_checkpoint", { withTimezone: true }), // highest Shopify updated_at seen
+    lastFullSyncAt: timestamp("last_full_sync_at", { withTimezone: true }),
+    syncCursor: text("sync_cursor"), // next page of an interrupted sync, null once a sync finishes
+    syncCursorSince: timestamp("sync_cursor_since", { withTimezone: true }), // updated_at filter of the interrupted sync
+    syncCursorFull: boolean("sync_cursor_full").default(false).notNull(),
+    syncSchedule: varchar("sync_schedule", { length: 100 }), // cron expression with seconds, e.g. '0 0 * * * *' or '@every 1h'; scraper default when null
+    syncPriority: integer("sync_priority").default(0).notNull(), // higher syncs first when brands are due together
+    syncIntervalSeconds: integer("sync_interval_seconds"), // learnt from recent sync_logs, used when sync_schedule is null
+    syncIntervalReason: varchar("sync_interval_reason", { length: 255 }), // why the scraper last changed the interval
+    syncIntervalSetAt: timestamp("sync_interval_set_at", { withTimezone: true }),
     isActive: boolean("is_active").default(true),
+    status: varchar("status", { length: 20 }).default("active").notNull(), // 'pending', 'active', 'failing', 'deactivated'
+    statusReason: varchar("status_reason", { length: 50 }), // why syncs fail: 'password_page', 'not_shopify', 'dns', 'tls', 'bot_wall', 'robots', 'error'
+    consecutiveFailures: integer("consecutive_failures").default(0).notNull(),
   },
   (table) => [
     index("idx_brands_slug").on(table.slug),
   ]
 );
 
+// Brand status events table - audit trail of the status changes the scraper makes
+export const brandStatusEvents = pgTable(
+  "brand_status_events",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    brandId: uuid("brand_id")
+      .notNull()
+      .references(() => brands.id, { onDelete: "cascade" }),
+    fromStatus: varchar("from_status", { length: 20 }),
+    toStatus: varchar("to_status", { length: 20 }).notNull(),
+    reason: varchar("reason", { length: 50 }),
+    message: text("message"),
+    createdAt: timestamp("created_at", { withTimezone: true }).defaultNow().notNull(),
+  },
+  (table) => [
+    index("idx_brand_status_events_brand_id").on(table.brandId, table.createdAt),
+  ]
+);
+
 // Categories table
 export const categories = pgTable(
   "categories",
@@ -71,10 +109,15 @@ export const products = pgTable(
     priceMin: decimal("price_min", { precision: 10, scale: 2 }),
     priceMax: decimal("price_max", { precision: 10, scale: 2 }),
     currency: varchar("currency", { length: 3 }).default("EUR"),
+    priceMinEur: decimal("price_min_eur", { precision: 10, scale: 2 }), // converted with the latest exchange rate
+    priceMaxEur: decimal("price_max_eur", { precision: 10, scale: 2 }),
     compareAtPrice: decimal("compare_at_price", { precision: 10, scale: 2 }),
     isAvailable: boolean("is_available").default(true),
     isNew: boolean("is_new").default(false),
     publishedAt: timestamp("published_at", { withTimezone: true }),
+    removedAt: timestamp("removed_at", { withTimezone: true }), // set when the store stops listing the product
+    contentHash: varchar("content_hash", { length: 64 }), // scraper skips the write when unchanged
+    canonicalUrl: varchar("canonical_url", { length: 1000 }), // identity of crawled products, shopify_id is derived from it
     createdAt: timestamp("created_at", { withTimezone: true }).defaultNow(),
     updatedAt: timestamp("updated_at", { withTimezone: true }).defaultNow(),
   },
@@ -82,6 +125,7 @@ export const products = pgTable(
     index("idx_products_brand_id").on(table.brandId),
     index("idx_products_product_type").on(table.productType),
     index("idx_products_price").on(table.priceMin, table.priceMax),
+    index("idx_products_price_eur").on(table.priceMinEur, table.priceMaxEur),
     index("idx_products_slug").on(table.slug),
     index("idx_products_is_new").on(table.isNew),
     unique("products_brand_shopify_unique").on(table.brandId, table.shopifyId),
@@ -134,6 +178,55 @@ export const productImages = pgTable(
   ]
 );
 
+// Price history table - one row per variant price change, written by the scraper
+export const priceHistory = pgTable(
+  "price_history",
+  {
+    id: uuid("id").primaryKey().defaultRandom(),
+    productId: uuid("product_id")
+      .notNull()
+      .references(() => products.id, { onDelete: "cascade" }),
+    variantShopifyId: bigint("variant_shopify_id", { mode: "number" }).notNull(),
+    price: decimal("price", { precision: 10, scale: 2 }).notNull(),
+    compareAtPrice: decimal("compare_at_price", { precision: 10, scale: 2 }),
+    recordedAt: timestamp("recorded_at", { withTimezone: true }).defaultNow().notNull(),
+  },
+  (table) => [
+    index("idx_price_history_product_variant").on(table.productId, table.variantShopifyId, table.recordedAt),
+    index("idx_price_history_recorded_at").on(table.recordedAt),
+  ]
+);
+
+// Exchange rates table - ECB reference rates imported by the scraper, in units of currency per EUR
+export const exchangeRates = pgTable(
+  "exchange_rates",
+  {
+    currency: varchar("currency", { length: 3 }).notNull(),
+    rateDate: date("rate_date").notNull(),
+    rate: decimal("rate", { precision: 18, scale: 6 }).notNull(),
+    createdAt: timestamp("created_at", { withTimezone: true }).defaultNow(),
+  },
+  (table) => [
+    primaryKey({ columns: [table.currency, table.rateDate] }),
+  ]
+);
+
+// Sync leases table - lets several scraper replicas share the work: 'scheduler' is held by the
+// replica running the schedule, 'brand:<id>' by the one syncing that brand. Expired leases are free.
+export const syncLeases = pgTable("sync_leases", {
+  name: varchar("name", { length: 255 }).primaryKey(),
+  holder: varchar("holder", { length: 255 }).notNull(), // replica ID
+  acquiredAt: timestamp("acquired_at", { withTimezone: true }).defaultNow().notNull(),
+  expiresAt: timestamp("expires_at", { withTimezone: true }).notNull(),
+});
+
+// Scraper settings table - state every scraper replica follows, such as 'paused'
+export const scraperSettings = pgTable("scraper_settings", {
+  key: varchar("key", { length: 100 }).primaryKey(),
+  value: text("value").notNull(),
+  updatedAt: timestamp("updated_at", { withTimezone: true }).defaultNow().notNull(),
+});
+
 // Product categories (many-to-many)
 export const productCategories = pgTable(
   "product_categories",
@@ -156,10 +249,15 @@ export const syncLogs = pgTable(
   {
     id: uuid("id").primaryKey().defaultRandom(),
     brandId: uuid("brand_id").references(() => brands.id),
-    status: varchar("status", { length: 50 }).notNull(), // 'pending', 'running', 'completed', 'failed'
+    status: varchar("status", { length: 50 }).notNull(), // 'pending', 'running', 'completed', 'failed', 'blocked', 'interrupted'
     productsFound: integer("products_found").default(0),
     productsCreated: integer("products_created").default(0),
     productsUpdated: integer("products_updated").default(0),
+    productsUnchanged: integer("products_unchanged").default(0),
+    productsRemoved: integer("products_removed").default(0),
+    productsRestored: integer("products_restored").default(0),
+    syncType: varchar("sync_type", { length: 20 }).default("full"), // 'full', 'incremental'
+    acquisitionMode: varchar("acquisition_mode", { length: 50 }), // 'products_json', 'sitemap', 'html', 'store_api', 'crawl'
     errorMessage: text("error_message"),
     startedAt: timestamp("started_at", { withTimezone: true }).defaultNow(),
     completedAt: timestamp("completed_at", { withTimezone: true }),
@@ -174,6 +272,7 @@ export const syncLogs = pgTable(
 export const brandsRelations = relations(brands, ({ many }) => ({
   products: many(products),
   syncLogs: many(syncLogs),
+  statusEvents: many(brandStatusEvents),
 }));
 
 export const categoriesRelations = relations(categories, ({ one, many }) => ({
@@ -193,6 +292,7 @@ export const productsRelations = relations(products, ({ one, many }) => ({
   }),
   variants: many(productVariants),
   images: many(productImages),
+  priceHistory: many(priceHistory),
   productCategories: many(productCategories),
   wishlist: many(wishlist),
 }));
@@ -211,6 +311,13 @@ export const productImagesRelations = relations(productImages, ({ one }) => ({
   }),
 }));
 
+export const priceHistoryRelations = relations(priceHistory, ({ one }) => ({
+  product: one(products, {
+    fields: [priceHistory.productId],
+    references: [products.id],
+  }),
+}));
+
 export const productCategoriesRelations = relations(productCategories, ({ one }) => ({
   product: one(products, {
     fields: [productCategories.productId],
@@ -229,6 +336,13 @@ export const syncLogsRelations = relations(syncLogs, ({ one }) => ({
   }),
 }));
 
+export const brandStatusEventsRelations = relations(brandStatusEvents, ({ one }) => ({
+  brand: one(brands, {
+    fields: [brandStatusEvents.brandId],
+    references: [brands.id],
+  }),
+}));
+
 // Users table for authentication
 export const users = pgTable(
   "users",